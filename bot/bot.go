@@ -0,0 +1,419 @@
+// Package bot routes "!command args" messages sent to a client to handlers.
+//
+// A Bot reads the events of a client.Client. Messages in chatrooms and direct
+// messages starting with the prefix are split into a command name and its
+// arguments and passed to the handler registered for the name, through any
+// middleware. Whatever the handler replies is sent back to the chatroom or
+// user the command came from.
+//
+//	b := bot.New(c)
+//	b.HandleFunc("roll", func(m *bot.Message) (*bot.Reply, error) {
+//		return bot.Say(strconv.Itoa(rand.Intn(6) + 1)), nil
+//	})
+//	b.Run()
+package bot
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/client"
+	"github.com/ccassise/waddle/internal/config"
+	"github.com/ccassise/waddle/internal/ratelimit"
+)
+
+// ErrRateLimited is passed to OnError when a user runs a command more often
+// than its rate allows. It is passed once until the user's bucket has tokens
+// again, and the attempts in between are dropped, so that flooding the bot
+// does not make it flood the chatroom in turn.
+var ErrRateLimited = errors.New(errRateLimited)
+
+// Options configure a Bot.
+type Options struct {
+	// Prefix starts every command. Empty means "!".
+	Prefix string
+
+	// OnError is called when a handler fails or a command is rate limited.
+	// When nil the error is replied to the user in place.
+	OnError func(m *Message, err error)
+
+	// OnEvent, when set, is called with every event from the client before
+	// commands are routed, such as notices the bot wants to act on.
+	OnEvent func(e client.Event)
+
+	// Concurrency is the most commands handled at once. Zero means 16.
+	Concurrency int
+}
+
+// Scope limits where a command may be used.
+type Scope int
+
+const (
+	// Anywhere allows the command in chatrooms and direct messages.
+	Anywhere Scope = iota
+	// RoomsOnly allows the command in chatrooms only.
+	RoomsOnly
+	// DirectOnly allows the command in direct messages only.
+	DirectOnly
+)
+
+// Command is a command the bot answers to.
+type Command struct {
+	// Name is what follows the prefix, such as "roll" for "!roll".
+	Name string
+
+	// Usage and Help describe the command in the reply to "!help". Usage is
+	// written without the prefix, such as "roll <sides>".
+	Usage string
+	Help  string
+
+	// Scope is where the command may be used.
+	Scope Scope
+
+	// Rate and Burst limit how often each user may run the command, as a
+	// token bucket refilled Rate times per second holding at most Burst
+	// tokens. A Rate of zero means unlimited, and a Burst below one is taken
+	// as one.
+	Rate  float64
+	Burst int
+
+	Handler Handler
+}
+
+// allows returns whether the command may be used where the message was sent.
+func (cmd *Command) allows(m *Message) bool {
+	switch cmd.Scope {
+	case RoomsOnly:
+		return !m.Direct()
+	case DirectOnly:
+		return m.Direct()
+	}
+	return true
+}
+
+// Handler runs a command. The reply may be nil when there is nothing to say.
+type Handler func(m *Message) (*Reply, error)
+
+// Middleware wraps a handler, such as to log commands or to check who may run
+// them. It runs for every command in the order it was added.
+type Middleware func(next Handler) Handler
+
+// Message is a command sent to the bot.
+type Message struct {
+	// From is the name of the user that sent the command.
+	From string
+
+	// Room is the chatroom the command was sent to, or empty for a direct
+	// message.
+	Room string
+
+	// Command is the name of the command without its prefix, and Args are
+	// the words after it. Rest is everything after the name with the spacing
+	// between words kept.
+	Command string
+	Args    []string
+	Rest    string
+
+	// Text is the whole message, including the command.
+	Text string
+}
+
+// Direct returns whether the command was sent as a direct message.
+func (m *Message) Direct() bool {
+	return m.Room == ""
+}
+
+// Reply is what a handler answers a command with. Each line is sent as its own
+// message, to the chatroom the command came from or to its sender.
+type Reply struct {
+	Lines []string
+
+	// Private sends the reply to the sender directly even when the command
+	// was sent to a chatroom.
+	Private bool
+
+	// Mention starts the first line with the sender's name, so it stands out
+	// to them in a busy chatroom.
+	Mention bool
+}
+
+// Say returns a reply of the given lines.
+func Say(lines ...string) *Reply {
+	return &Reply{Lines: lines}
+}
+
+// Whisper returns a reply of the given lines sent only to the sender.
+func Whisper(lines ...string) *Reply {
+	return &Reply{Lines: lines, Private: true}
+}
+
+// Bot answers commands sent to a client.
+type Bot struct {
+	c    *client.Client
+	opts Options
+
+	mu         sync.Mutex
+	commands   map[string]*route
+	middleware []Middleware
+	now        func() time.Time
+}
+
+// route is a registered command with the limits of each user.
+type route struct {
+	cmd    Command
+	limits map[string]*limit
+}
+
+// limit is how often a user may run a command. warned is whether the user has
+// been told about running out of tokens since last running the command.
+type limit struct {
+	bucket *ratelimit.Bucket
+	warned bool
+}
+
+// prune drops the limits whose buckets have refilled, since a user without
+// one gets a full one.
+func (r *route) prune(now time.Time) {
+	for name, l := range r.limits {
+		if l.bucket.Full(now) {
+			delete(r.limits, name)
+		}
+	}
+}
+
+// New returns a bot answering commands sent to the client with the default
+// options.
+func New(c *client.Client) *Bot {
+	return NewWithOptions(c, Options{})
+}
+
+func NewWithOptions(c *client.Client, opts Options) *Bot {
+	if opts.Prefix == "" {
+		opts.Prefix = "!"
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+
+	return &Bot{
+		c:        c,
+		opts:     opts,
+		commands: make(map[string]*route),
+		now:      time.Now,
+	}
+}
+
+// Handle registers the command, replacing any with the same name. A "help"
+// command listing the others is answered unless one is registered.
+func (b *Bot) Handle(cmd Command) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.commands[strings.ToLower(cmd.Name)] = &route{
+		cmd:    cmd,
+		limits: make(map[string]*limit),
+	}
+}
+
+// HandleFunc registers a handler for the named command that may be used
+// anywhere without a rate limit.
+func (b *Bot) HandleFunc(name string, h Handler) {
+	b.Handle(Command{Name: name, Handler: h})
+}
+
+// Use adds middleware run around every handler.
+func (b *Bot) Use(mw ...Middleware) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.middleware = append(b.middleware, mw...)
+}
+
+// Run answers commands until the client is closed. Each command is handled
+// in its own goroutine, up to Options.Concurrency at once, and Run waits for
+// those still running before it returns.
+func (b *Bot) Run() {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	sem := make(chan struct{}, b.opts.Concurrency)
+
+	for e := range b.c.Events() {
+		if b.opts.OnEvent != nil {
+			b.opts.OnEvent(e)
+		}
+
+		m := b.parse(e)
+		if m == nil {
+			continue
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			b.dispatch(m)
+		}()
+	}
+}
+
+// parse returns the command in the event, or nil when it is not a message
+// starting with the prefix or was sent by the bot itself.
+func (b *Bot) parse(e client.Event) *Message {
+	var m Message
+	switch e := e.(type) {
+	case client.RoomMessage:
+		m = Message{From: e.From, Room: e.Room, Text: e.Text}
+	case client.UserMessage:
+		m = Message{From: e.From, Text: e.Text}
+	default:
+		return nil
+	}
+
+	if m.From == b.c.Name() || !strings.HasPrefix(m.Text, b.opts.Prefix) {
+		return nil
+	}
+
+	text := strings.TrimSpace(m.Text[len(b.opts.Prefix):])
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return nil
+	}
+	m.Command = strings.ToLower(fields[0])
+	m.Args = fields[1:]
+	m.Rest = strings.TrimSpace(text[len(fields[0]):])
+
+	return &m
+}
+
+// dispatch runs the handler of the command and sends its reply. Unknown
+// commands and commands used outside their scope are ignored, so several bots
+// can share a chatroom.
+func (b *Bot) dispatch(m *Message) {
+	h, err := b.handler(m)
+	if h == nil && err == nil {
+		return
+	}
+
+	var reply *Reply
+	if err == nil {
+		reply, err = h(m)
+	}
+
+	if err != nil {
+		if b.opts.OnError != nil {
+			b.opts.OnError(m, err)
+			return
+		}
+		reply = &Reply{Lines: []string{"error: " + err.Error()}, Mention: true}
+	}
+
+	b.Send(m, reply)
+}
+
+// handler returns the command's handler wrapped in middleware, nil if the
+// command does not apply or the user has already been told it is rate
+// limited, or ErrRateLimited.
+func (b *Bot) handler(m *Message) (Handler, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	r, ok := b.commands[m.Command]
+	if !ok {
+		if m.Command != "help" {
+			return nil, nil
+		}
+		r = &route{cmd: Command{Handler: b.help}}
+	}
+
+	if !r.cmd.allows(m) {
+		return nil, nil
+	}
+
+	if r.cmd.Rate > 0 {
+		l, ok := r.limits[m.From]
+		if !ok {
+			r.prune(b.now())
+			l = &limit{bucket: ratelimit.NewBucket(config.Rate{PerSecond: r.cmd.Rate, Burst: r.cmd.Burst})}
+			r.limits[m.From] = l
+		}
+		if !l.bucket.Take(b.now()) {
+			if l.warned {
+				return nil, nil
+			}
+			l.warned = true
+			return nil, ErrRateLimited
+		}
+		l.warned = false
+	}
+
+	h := r.cmd.Handler
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		h = b.middleware[i](h)
+	}
+
+	return h, nil
+}
+
+// Send sends the reply to where the message came from. Sending stops at the
+// first line that fails.
+func (b *Bot) Send(m *Message, reply *Reply) error {
+	if reply == nil {
+		return nil
+	}
+
+	to := m.Room
+	if to == "" || reply.Private {
+		to = m.From
+	}
+
+	for i, line := range reply.Lines {
+		if i == 0 && reply.Mention && !reply.Private && !m.Direct() {
+			line = m.From + ": " + line
+		}
+		if err := b.c.Msg(to, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// help lists the commands that may be used where it was asked.
+func (b *Bot) help(m *Message) (*Reply, error) {
+	b.mu.Lock()
+	names := make([]string, 0, len(b.commands))
+	for name, r := range b.commands {
+		if r.cmd.allows(m) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		cmd := b.commands[name].cmd
+		line := b.opts.Prefix + name
+		if cmd.Usage != "" {
+			line = b.opts.Prefix + cmd.Usage
+		}
+		if cmd.Help != "" {
+			line += " - " + cmd.Help
+		}
+		lines = append(lines, line)
+	}
+	b.mu.Unlock()
+
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	return Whisper(lines...), nil
+}
+
+const (
+	errRateLimited = "slow down, try again later"
+)
@@ -0,0 +1,325 @@
+package bot
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/client"
+)
+
+// fakeServer answers every line with OK and lets the test send lines to the
+// bot's connection.
+type fakeServer struct {
+	conn  net.Conn
+	lines chan string
+}
+
+// newBot connects a bot logged in as "bot" to a fake server and runs it.
+func newBot(t *testing.T, opts Options) (*Bot, *fakeServer) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() = %v, want %v", err, nil)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeServer{lines: make(chan string, 100)}
+	accepted := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s.conn = conn
+		close(accepted)
+
+		conn.Write([]byte("HELLO\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			s.lines <- strings.TrimRight(line, "\r\n")
+			conn.Write([]byte("OK\r\n"))
+		}
+	}()
+
+	c, err := client.Dial(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial() = %v, want %v", err, nil)
+	}
+	<-accepted
+	c.Login("bot", "")
+	<-s.lines
+
+	b := NewWithOptions(c, opts)
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		c.Close()
+		<-done
+	})
+	go func() {
+		b.Run()
+		close(done)
+	}()
+
+	return b, s
+}
+
+// send sends a line to the bot.
+func (s *fakeServer) send(line string) {
+	s.conn.Write([]byte(line + "\r\n"))
+}
+
+// next returns the next line the bot sent, or an empty string if it sent
+// nothing.
+func (s *fakeServer) next() string {
+	select {
+	case line := <-s.lines:
+		return line
+	case <-time.After(200 * time.Millisecond):
+		return ""
+	}
+}
+
+func echo(m *Message) (*Reply, error) {
+	return Say(m.Rest), nil
+}
+
+func TestBot(t *testing.T) {
+	t.Run("should reply to command in chatroom", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.HandleFunc("echo", echo)
+
+		s.send("GOTROOMMSG alice #room !echo  hello   there ")
+		reply := s.next()
+
+		expect := "MSG #room hello   there"
+		if reply != expect {
+			t.Fatalf("sent %q, want %q", reply, expect)
+		}
+	})
+
+	t.Run("should reply to direct command directly", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.HandleFunc("ECHO", echo)
+
+		s.send("GOTUSERMSG alice !Echo hi")
+		reply := s.next()
+
+		expect := "MSG alice hi"
+		if reply != expect {
+			t.Fatalf("sent %q, want %q", reply, expect)
+		}
+	})
+
+	t.Run("should ignore unknown commands, other text and itself", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.HandleFunc("echo", echo)
+
+		s.send("GOTROOMMSG alice #room !roll 6")
+		s.send("GOTROOMMSG alice #room echo hi")
+		s.send("GOTROOMMSG bot #room !echo hi")
+		reply := s.next()
+
+		if reply != "" {
+			t.Fatalf("sent %q, want nothing", reply)
+		}
+	})
+
+	t.Run("should use prefix", func(t *testing.T) {
+		b, s := newBot(t, Options{Prefix: "."})
+		b.HandleFunc("echo", echo)
+
+		s.send("GOTROOMMSG alice #room !echo no")
+		s.send("GOTROOMMSG alice #room .echo yes")
+		reply := s.next()
+
+		if reply != "MSG #room yes" {
+			t.Fatalf("sent %q, want %q", reply, "MSG #room yes")
+		}
+	})
+
+	t.Run("should send structured replies", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.HandleFunc("status", func(m *Message) (*Reply, error) {
+			return &Reply{Lines: []string{"build passed", "deployed"}, Mention: true}, nil
+		})
+		b.HandleFunc("secret", func(m *Message) (*Reply, error) {
+			return Whisper("psst"), nil
+		})
+
+		s.send("GOTROOMMSG alice #room !status")
+		sent := []string{s.next(), s.next()}
+		s.send("GOTROOMMSG alice #room !secret")
+		sent = append(sent, s.next())
+
+		expect := []string{"MSG #room alice: build passed", "MSG #room deployed", "MSG alice psst"}
+		if strings.Join(sent, "|") != strings.Join(expect, "|") {
+			t.Fatalf("sent %q, want %q", sent, expect)
+		}
+	})
+
+	t.Run("should run middleware in order", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		order := make(chan string, 10)
+		mark := func(name string) Middleware {
+			return func(next Handler) Handler {
+				return func(m *Message) (*Reply, error) {
+					order <- name
+					return next(m)
+				}
+			}
+		}
+		b.Use(mark("first"), mark("second"))
+		b.Use(func(next Handler) Handler {
+			return func(m *Message) (*Reply, error) {
+				if m.From != "admin" {
+					return nil, errors.New("not allowed")
+				}
+				return next(m)
+			}
+		})
+		b.HandleFunc("echo", echo)
+
+		s.send("GOTROOMMSG alice #room !echo hi")
+		reply := s.next()
+
+		ran := []string{<-order, <-order}
+		if reply != "MSG #room alice: error: not allowed" || strings.Join(ran, " ") != "first second" {
+			t.Fatalf("sent %q, order %v; want error and first second", reply, ran)
+		}
+	})
+
+	t.Run("should limit rate of each user", func(t *testing.T) {
+		errs := make(chan error, 10)
+		b, s := newBot(t, Options{OnError: func(m *Message, err error) { errs <- err }})
+		b.Handle(Command{Name: "echo", Rate: 0.001, Burst: 1, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !echo one")
+		first := s.next()
+		s.send("GOTROOMMSG alice #room !echo two")
+		second := s.next()
+		s.send("GOTROOMMSG bob #room !echo three")
+		third := s.next()
+
+		if first != "MSG #room one" || second != "" || third != "MSG #room three" || len(errs) != 1 || <-errs != ErrRateLimited {
+			t.Fatalf("sent %q %q %q; want one, nothing, three and ErrRateLimited", first, second, third)
+		}
+	})
+
+	t.Run("should warn rate limited user once", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.Handle(Command{Name: "echo", Rate: 0.001, Burst: 1, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !echo one")
+		s.next()
+		s.send("GOTROOMMSG alice #room !echo two")
+		warning := s.next()
+		s.send("GOTROOMMSG alice #room !echo three")
+		dropped := s.next()
+
+		expect := "MSG #room alice: error: " + errRateLimited
+		if warning != expect || dropped != "" {
+			t.Fatalf("sent %q %q, want %q and nothing", warning, dropped, expect)
+		}
+	})
+
+	t.Run("should bound commands handled at once", func(t *testing.T) {
+		b, s := newBot(t, Options{Concurrency: 1})
+		release := make(chan struct{})
+		b.HandleFunc("wait", func(m *Message) (*Reply, error) {
+			<-release
+			return Say("waited"), nil
+		})
+		b.HandleFunc("echo", echo)
+
+		s.send("GOTROOMMSG alice #room !wait")
+		s.send("GOTROOMMSG bob #room !echo hi")
+		blocked := s.next()
+		close(release)
+		first := s.next()
+		second := s.next()
+
+		if blocked != "" || first != "MSG #room waited" || second != "MSG #room hi" {
+			t.Fatalf("sent %q %q %q; want nothing, waited and hi", blocked, first, second)
+		}
+	})
+
+	t.Run("should allow one run at a time without a burst", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.Handle(Command{Name: "echo", Rate: 0.001, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !echo one")
+		reply := s.next()
+
+		if reply != "MSG #room one" {
+			t.Fatalf("sent %q, want %q", reply, "MSG #room one")
+		}
+	})
+
+	t.Run("should forget users whose buckets have refilled", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		now := time.Unix(1000, 0)
+		b.now = func() time.Time { return now }
+		b.Handle(Command{Name: "echo", Rate: 1, Burst: 1, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !echo one")
+		s.next()
+		b.mu.Lock()
+		now = now.Add(time.Second)
+		b.mu.Unlock()
+		s.send("GOTROOMMSG bob #room !echo two")
+		s.next()
+
+		b.mu.Lock()
+		_, kept := b.commands["echo"].limits["alice"]
+		b.mu.Unlock()
+		if kept {
+			t.Fatalf("bucket of alice kept after refilling")
+		}
+	})
+
+	t.Run("should only answer commands in their scope", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.Handle(Command{Name: "echo", Scope: DirectOnly, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !echo room")
+		s.send("GOTUSERMSG alice !echo direct")
+		reply := s.next()
+
+		if reply != "MSG alice direct" {
+			t.Fatalf("sent %q, want %q", reply, "MSG alice direct")
+		}
+	})
+
+	t.Run("should list commands for help", func(t *testing.T) {
+		b, s := newBot(t, Options{})
+		b.Handle(Command{Name: "roll", Usage: "roll <sides>", Help: "roll a die", Handler: echo})
+		b.Handle(Command{Name: "echo", Scope: DirectOnly, Handler: echo})
+
+		s.send("GOTROOMMSG alice #room !help")
+		reply := s.next()
+		extra := s.next()
+
+		if reply != "MSG alice !roll <sides> - roll a die" || extra != "" {
+			t.Fatalf("sent %q %q, want %q only", reply, extra, "MSG alice !roll <sides> - roll a die")
+		}
+	})
+
+	t.Run("should pass events to OnEvent", func(t *testing.T) {
+		events := make(chan client.Event, 10)
+		_, s := newBot(t, Options{OnEvent: func(e client.Event) { events <- e }})
+
+		s.send("INVITED alice #room")
+		e := <-events
+
+		if n, ok := e.(client.Notice); !ok || n.Command != "INVITED" {
+			t.Fatalf("OnEvent(%+v), want INVITED notice", e)
+		}
+	})
+}
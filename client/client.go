@@ -0,0 +1,701 @@
+// Package client is a client for waddle chat servers.
+//
+// A Client sends one command at a time and waits for the server's OK or
+// ERROR, so each answer always belongs to the command that caused it. Lines
+// the server sends unprompted, such as messages from other users, are
+// delivered on the Events channel, which must be read from.
+package client
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+)
+
+// Options configure a Client. The zero value connects over TCP, waits 30
+// seconds for answers and does not reconnect.
+type Options struct {
+	// Dial opens a connection to the server. When nil a TCP connection is
+	// made to the address given to DialWithOptions.
+	Dial func() (net.Conn, error)
+
+	// Timeout is how long to wait for the server to answer a command. Zero
+	// means 30 seconds.
+	Timeout time.Duration
+
+	// Reconnect makes the client connect again when the connection is lost,
+	// log back in and rejoin its chatrooms.
+	Reconnect bool
+
+	// MinBackoff and MaxBackoff bound the wait between reconnect attempts,
+	// which doubles after each failure. Zero means 1 second and 1 minute.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Error is an ERROR sent by the server in answer to a command.
+type Error struct {
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return e.Reason
+}
+
+// Client is a connection to a waddle server.
+type Client struct {
+	opts   Options
+	events chan Event
+	lost   chan struct{}
+	done   chan struct{}
+
+	// reqMu makes sure only one command waits for an answer at a time.
+	reqMu sync.Mutex
+
+	mu       sync.Mutex
+	wake     *sync.Cond
+	conn     net.Conn
+	pending  *request
+	name     string
+	password string
+	rooms    []string
+	queue    []Event
+	quitting bool
+	closed   bool
+
+	// reconnecting is set while reconnect is running, which deals with
+	// connections it opens failing.
+	reconnecting bool
+}
+
+// request is a command waiting for its answer. Lines starting with reply are
+// part of the answer.
+type request struct {
+	reply string
+	lines []string
+	done  chan error
+}
+
+// Dial connects to the server at the given TCP address.
+func Dial(addr string) (*Client, error) {
+	return DialWithOptions(addr, Options{})
+}
+
+// DialWithOptions connects to the server at the given address, or with
+// opts.Dial when it is set.
+func DialWithOptions(addr string, opts Options) (*Client, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.MinBackoff == 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff == 0 {
+		opts.MaxBackoff = time.Minute
+	}
+	if opts.Dial == nil {
+		timeout := opts.Timeout
+		opts.Dial = func() (net.Conn, error) {
+			return net.DialTimeout("tcp", addr, timeout)
+		}
+	}
+
+	c := &Client{
+		opts:   opts,
+		events: make(chan Event),
+		lost:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	c.wake = sync.NewCond(&c.mu)
+
+	conn, r, err := c.connect()
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+
+	go c.read(conn, r)
+	go c.pump()
+	go c.supervise()
+
+	return c, nil
+}
+
+// Events returns the channel events are delivered on. It is closed once the
+// client is closed.
+func (c *Client) Events() <-chan Event {
+	return c.events
+}
+
+// Name returns the name the client is logged in as, or an empty string.
+func (c *Client) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.name
+}
+
+// Rooms returns the chatrooms the client is in.
+func (c *Client) Rooms() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]string(nil), c.rooms...)
+}
+
+// Login logs in as the given name. The password may be empty when the server
+// does not need one.
+func (c *Client) Login(name string, password string) error {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if err := c.login(name, password); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.name = name
+	c.password = password
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Join joins the chatroom, creating it if needed.
+func (c *Client) Join(room string) error {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if err := c.join(room); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.addRoom(room)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Part leaves the chatroom.
+func (c *Client) Part(room string) error {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if err := checkArgs(room); err != nil {
+		return err
+	}
+
+	if _, err := c.do(message.StringifyCommand(message.Part)+" "+room, ""); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.removeRoom(room)
+	c.mu.Unlock()
+
+	return nil
+}
+
+// Msg sends the text to a chatroom, when to begins with '#', or to a user.
+func (c *Client) Msg(to string, text string) error {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if err := checkArgs(to); err != nil {
+		return err
+	}
+	if strings.ContainsAny(text, "\r\n") {
+		return errors.New(errNewline)
+	}
+
+	_, err := c.do(message.StringifyCommand(message.Msg)+" "+to+" "+text, "")
+	return err
+}
+
+// Logout logs out and closes the client.
+func (c *Client) Logout() error {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	c.mu.Lock()
+	c.quitting = true
+	c.mu.Unlock()
+
+	_, err := c.do(message.StringifyCommand(message.Logout), "")
+	c.Close()
+
+	return err
+}
+
+// Command sends any other command line, without its CRLF, and returns the
+// lines the server answered with before its OK. For example Command("LIST")
+// returns one "LIST #<chatroom> <users>" line per chatroom.
+func (c *Client) Command(line string) ([]string, error) {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	if strings.ContainsAny(line, "\r\n") {
+		return nil, errors.New(errNewline)
+	}
+
+	keyword := line
+	if i := strings.IndexByte(line, ' '); i >= 0 {
+		keyword = line[:i]
+	}
+
+	reply := keyword + " "
+	if keyword == message.StringifyCommand(message.Mode) {
+		// A MODE query is answered with MODES, as MODE lines tell of changes.
+		reply = "MODES "
+	}
+
+	return c.do(line, reply)
+}
+
+// Close closes the connection without logging out. Events not yet read are
+// dropped.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	conn := c.conn
+	c.conn = nil
+	close(c.done)
+	c.wake.Broadcast()
+	c.mu.Unlock()
+
+	if conn != nil {
+		return conn.Close()
+	}
+	return nil
+}
+
+func (c *Client) login(name string, password string) error {
+	if err := checkArgs(name, password); err != nil {
+		return err
+	}
+
+	line := message.StringifyCommand(message.Login) + " " + name
+	if password != "" {
+		line += " " + password
+	}
+
+	_, err := c.do(line, "")
+	return err
+}
+
+func (c *Client) join(room string) error {
+	if err := checkArgs(room); err != nil {
+		return err
+	}
+
+	_, err := c.do(message.StringifyCommand(message.Join)+" "+room, "")
+	return err
+}
+
+// do sends the command line and waits for its answer. The caller must hold
+// reqMu.
+func (c *Client) do(line string, reply string) ([]string, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New(errClosed)
+	}
+	conn := c.conn
+	if conn == nil {
+		c.mu.Unlock()
+		return nil, errors.New(errDisconnected)
+	}
+	p := &request{reply: reply, done: make(chan error, 1)}
+	c.pending = p
+	c.mu.Unlock()
+
+	if _, err := conn.Write([]byte(line + "\r\n")); err != nil {
+		c.clearPending(p)
+		return nil, err
+	}
+
+	timer := time.NewTimer(c.opts.Timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-p.done:
+		return p.lines, err
+	case <-timer.C:
+		// A late answer could be mistaken for the answer to the next command,
+		// so give up on the connection.
+		c.clearPending(p)
+		conn.Close()
+		return nil, errors.New(errTimeout)
+	}
+}
+
+func (c *Client) clearPending(p *request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == p {
+		c.pending = nil
+	}
+}
+
+// connect opens a connection and waits for the server's HELLO.
+func (c *Client) connect() (net.Conn, *bufio.Reader, error) {
+	conn, err := c.opts.Dial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(c.opts.Timeout))
+	r := bufio.NewReader(conn)
+	line, err := readLine(r)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if line != "HELLO" {
+		conn.Close()
+		if strings.HasPrefix(line, "ERROR ") {
+			return nil, nil, &Error{Reason: line[len("ERROR "):]}
+		}
+		return nil, nil, errors.New(errGreeting)
+	}
+
+	return conn, r, nil
+}
+
+// read handles lines from the connection until it fails.
+func (c *Client) read(conn net.Conn, r *bufio.Reader) {
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			c.disconnected(conn, err)
+			return
+		}
+
+		c.handle(conn, line)
+	}
+}
+
+// handle acts on a single line from the server.
+func (c *Client) handle(conn net.Conn, line string) {
+	keyword, rest := cut(line)
+
+	switch keyword {
+	case "OK":
+		c.finish(nil)
+	case "ERROR":
+		if !c.finish(&Error{Reason: rest}) {
+			c.emit(Notice{Command: keyword, Args: strings.Fields(rest), Line: line})
+		}
+	case message.StringifyCommand(message.Ping):
+		// The server does not answer PONG, so a command waiting for its
+		// answer is not completed by anything the PONG causes.
+		conn.Write([]byte(message.StringifyCommand(message.Pong) + " " + rest + "\r\n"))
+	case "GOTROOMMSG":
+		from, rest := cut(rest)
+		room, text := cut(rest)
+		c.emit(RoomMessage{From: from, Room: room, Text: text})
+	case "GOTUSERMSG":
+		from, text := cut(rest)
+		c.emit(UserMessage{From: from, Text: text})
+	default:
+		if c.answer(line) {
+			return
+		}
+
+		args := strings.Fields(rest)
+		c.track(keyword, args)
+		c.emit(Notice{Command: keyword, Args: args, Line: line})
+	}
+}
+
+// finish completes the pending command with the given result. Returns false
+// when no command is pending.
+func (c *Client) finish(err error) bool {
+	c.mu.Lock()
+	p := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if p == nil {
+		return false
+	}
+
+	p.done <- err
+	return true
+}
+
+// answer adds the line to the answer of the pending command if it is part of
+// it.
+func (c *Client) answer(line string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.pending
+	if p == nil || p.reply == "" || !strings.HasPrefix(line, p.reply) {
+		return false
+	}
+
+	p.lines = append(p.lines, line)
+	return true
+}
+
+// track keeps the list of chatrooms up to date when the server removes the
+// client from one or renames one.
+func (c *Client) track(keyword string, args []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case keyword == "KICKED" && len(args) >= 3 && args[2] == c.name:
+		c.removeRoom(args[1])
+	case keyword == "CLOSED" && len(args) >= 2:
+		c.removeRoom(args[1])
+	case keyword == "RENAMED" && len(args) >= 3:
+		for i := range c.rooms {
+			if c.rooms[i] == args[1] {
+				c.rooms[i] = args[2]
+			}
+		}
+	}
+}
+
+func (c *Client) addRoom(room string) {
+	for _, r := range c.rooms {
+		if r == room {
+			return
+		}
+	}
+	c.rooms = append(c.rooms, room)
+}
+
+func (c *Client) removeRoom(room string) {
+	for i := range c.rooms {
+		if c.rooms[i] == room {
+			c.rooms = append(c.rooms[:i], c.rooms[i+1:]...)
+			return
+		}
+	}
+}
+
+// disconnected fails the pending command and, when conn is still the current
+// connection, reports it lost.
+func (c *Client) disconnected(conn net.Conn, err error) {
+	conn.Close()
+
+	c.mu.Lock()
+	current := c.conn == conn
+	if current {
+		c.conn = nil
+	}
+	quiet := c.closed || c.quitting || c.reconnecting
+	c.mu.Unlock()
+
+	c.finish(errors.New(errDisconnected))
+
+	if !current || quiet {
+		return
+	}
+
+	c.emit(Disconnected{Err: err})
+	c.lost <- struct{}{}
+}
+
+// supervise reconnects each time the connection is lost, or closes the client
+// when reconnecting is disabled.
+func (c *Client) supervise() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.lost:
+			if !c.opts.Reconnect {
+				c.Close()
+				return
+			}
+
+			c.reconnect()
+		}
+	}
+}
+
+// reconnect connects again, logs back in and rejoins chatrooms, waiting longer
+// after each failed attempt. Commands wait until it is done.
+func (c *Client) reconnect() {
+	c.reqMu.Lock()
+	defer c.reqMu.Unlock()
+
+	c.mu.Lock()
+	c.reconnecting = true
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.reconnecting = false
+		c.mu.Unlock()
+	}()
+
+	backoff := c.opts.MinBackoff
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+
+		conn, r, err := c.connect()
+		if err != nil {
+			continue
+		}
+
+		c.mu.Lock()
+		if c.closed {
+			c.mu.Unlock()
+			conn.Close()
+			return
+		}
+		c.conn = conn
+		name, password := c.name, c.password
+		rooms := append([]string(nil), c.rooms...)
+		c.mu.Unlock()
+
+		go c.read(conn, r)
+
+		if name != "" {
+			if err := c.login(name, password); err != nil {
+				// The server may not have noticed the old connection is gone
+				// and still hold the name, so try again later.
+				c.drop(conn)
+				continue
+			}
+		}
+
+		var failed []string
+		lost := false
+		for _, room := range rooms {
+			err := c.join(room)
+			if _, refused := err.(*Error); refused {
+				failed = append(failed, room)
+			} else if err != nil {
+				lost = true
+				break
+			}
+		}
+		if lost {
+			c.drop(conn)
+			continue
+		}
+
+		c.mu.Lock()
+		for _, room := range failed {
+			c.removeRoom(room)
+		}
+		c.mu.Unlock()
+
+		c.emit(Reconnected{Failed: failed})
+		return
+	}
+}
+
+// drop closes the connection if it is the current one.
+func (c *Client) drop(conn net.Conn) {
+	c.mu.Lock()
+	if c.conn == conn {
+		c.conn = nil
+	}
+	c.mu.Unlock()
+
+	conn.Close()
+}
+
+// emit queues an event for delivery. Events are queued rather than sent
+// directly so that reading from the server never waits on the reader of the
+// Events channel, which may itself be waiting for an answer.
+func (c *Client) emit(e Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.queue = append(c.queue, e)
+	c.wake.Signal()
+}
+
+// pump delivers queued events in order until the client is closed.
+func (c *Client) pump() {
+	defer close(c.events)
+
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.closed {
+			c.wake.Wait()
+		}
+		if c.closed {
+			c.queue = nil
+			c.mu.Unlock()
+			return
+		}
+		e := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mu.Unlock()
+
+		select {
+		case c.events <- e:
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// readLine reads a line and strips its line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// cut splits off the first space separated word of s.
+func cut(s string) (string, string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+// checkArgs checks that none of the arguments would break up the command
+// line.
+func checkArgs(args ...string) error {
+	for _, arg := range args {
+		if strings.ContainsAny(arg, " \r\n") {
+			return errors.New(errInvalidArg)
+		}
+	}
+	return nil
+}
+
+const (
+	errClosed       = "client closed"
+	errDisconnected = "disconnected"
+	errGreeting     = "server did not say HELLO"
+	errInvalidArg   = "arguments may not contain spaces or line breaks"
+	errNewline      = "text may not contain line breaks"
+	errTimeout      = "timed out waiting for server"
+)
@@ -0,0 +1,245 @@
+package client
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer accepts connections, greets each with HELLO and passes each line
+// it reads, along with the connection's number starting from zero, to handle.
+type fakeServer struct {
+	ln     net.Listener
+	lines  chan string
+	handle func(n int, conn net.Conn, line string)
+}
+
+func newFakeServer(t *testing.T, handle func(n int, conn net.Conn, line string)) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() = %v, want %v", err, nil)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeServer{ln: ln, lines: make(chan string, 100), handle: handle}
+	go s.serve()
+
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for n := 0; ; n++ {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(n int, conn net.Conn) {
+			defer conn.Close()
+
+			conn.Write([]byte("HELLO\r\n"))
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				s.lines <- line
+				s.handle(n, conn, line)
+			}
+		}(n, conn)
+	}
+}
+
+// ok answers every line with OK.
+func ok(n int, conn net.Conn, line string) {
+	conn.Write([]byte("OK\r\n"))
+}
+
+// nextEvent returns the next event or fails the test.
+func nextEvent(t *testing.T, c *Client) Event {
+	select {
+	case e := <-c.Events():
+		return e
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Run("should send commands and return OK as nil", func(t *testing.T) {
+		s := newFakeServer(t, ok)
+		c, err := Dial(s.ln.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() = %v, want %v", err, nil)
+		}
+		defer c.Close()
+
+		login := c.Login("alice", "")
+		join := c.Join("#room")
+		msg := c.Msg("#room", "hello there")
+
+		sent := []string{<-s.lines, <-s.lines, <-s.lines}
+		expect := []string{"LOGIN alice", "JOIN #room", "MSG #room hello there"}
+		if login != nil || join != nil || msg != nil || strings.Join(sent, "|") != strings.Join(expect, "|") {
+			t.Fatalf("sent %q, want %q; errors %v %v %v", sent, expect, login, join, msg)
+		}
+	})
+
+	t.Run("should return ERROR as Error", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Write([]byte("ERROR username already in use\r\n"))
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		err := c.Login("alice", "")
+
+		e, isError := err.(*Error)
+		if !isError || e.Reason != "username already in use" || c.Name() != "" {
+			t.Fatalf("Login() = %v, want Error %q", err, "username already in use")
+		}
+	})
+
+	t.Run("should deliver messages as events", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Write([]byte("GOTROOMMSG bob #room hi alice\r\nGOTUSERMSG bob psst\r\nQUIT carol ping timeout\r\nOK\r\n"))
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		c.Join("#room")
+		room := nextEvent(t, c)
+		user := nextEvent(t, c)
+		notice := nextEvent(t, c)
+
+		expectRoom := RoomMessage{From: "bob", Room: "#room", Text: "hi alice"}
+		expectUser := UserMessage{From: "bob", Text: "psst"}
+		n, isNotice := notice.(Notice)
+		if room != expectRoom || user != expectUser || !isNotice || n.Command != "QUIT" || len(n.Args) != 3 {
+			t.Fatalf("events = %+v %+v %+v, want %+v %+v QUIT", room, user, notice, expectRoom, expectUser)
+		}
+	})
+
+	t.Run("should answer PING with PONG", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			if line == "LOGIN alice" {
+				conn.Write([]byte("PING abc123\r\nOK\r\n"))
+			}
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		c.Login("alice", "")
+		<-s.lines
+		pong := <-s.lines
+
+		if pong != "PONG abc123" {
+			t.Fatalf("sent %q, want %q", pong, "PONG abc123")
+		}
+	})
+
+	t.Run("should keep waiting for the answer while answering PING", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			switch line {
+			case "JOIN #room":
+				conn.Write([]byte("PING abc123\r\n"))
+			case "PONG abc123":
+				conn.Write([]byte("ERROR banned from room\r\n"))
+			}
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		err := c.Join("#room")
+
+		if e, ok := err.(*Error); !ok || e.Reason != "banned from room" {
+			t.Fatalf("Join() = %v, want %q", err, "banned from room")
+		}
+	})
+
+	t.Run("should collect answer lines of other commands", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Write([]byte("LIST #a 2\r\nMODE bob #a +m\r\nLIST #b 1\r\nOK\r\n"))
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		lines, err := c.Command("LIST")
+		notice := nextEvent(t, c)
+
+		if err != nil || len(lines) != 2 || lines[1] != "LIST #b 1" || notice.(Notice).Command != "MODE" {
+			t.Fatalf("Command() = (%q, %v), want two LIST lines; event %+v", lines, err, notice)
+		}
+	})
+
+	t.Run("should collect MODES in answer to MODE", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Write([]byte("MODE bob #a +m\r\nMODES #a +mt\r\nOK\r\n"))
+		})
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		lines, err := c.Command("MODE #a")
+		notice := nextEvent(t, c)
+
+		if err != nil || len(lines) != 1 || lines[0] != "MODES #a +mt" || notice.(Notice).Command != "MODE" {
+			t.Fatalf("Command() = (%q, %v), want one MODES line; event %+v", lines, err, notice)
+		}
+	})
+
+	t.Run("should reject text with line breaks", func(t *testing.T) {
+		s := newFakeServer(t, ok)
+		c, _ := Dial(s.ln.Addr().String())
+		defer c.Close()
+
+		err := c.Msg("#room", "hi\r\nLOGOUT")
+
+		if err == nil {
+			t.Fatalf("Msg() = %v, want error", err)
+		}
+	})
+
+	t.Run("should reconnect, log in and rejoin", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Write([]byte("OK\r\n"))
+			if n == 0 && line == "JOIN #room" {
+				conn.Close()
+			}
+		})
+		c, _ := DialWithOptions(s.ln.Addr().String(), Options{Reconnect: true, MinBackoff: time.Millisecond})
+		defer c.Close()
+
+		c.Login("alice", "secret")
+		c.Join("#room")
+		disconnected := nextEvent(t, c)
+		reconnected := nextEvent(t, c)
+
+		sent := []string{<-s.lines, <-s.lines, <-s.lines, <-s.lines}
+		expect := []string{"LOGIN alice secret", "JOIN #room", "LOGIN alice secret", "JOIN #room"}
+		_, isDisconnected := disconnected.(Disconnected)
+		_, isReconnected := reconnected.(Reconnected)
+		if !isDisconnected || !isReconnected || strings.Join(sent, "|") != strings.Join(expect, "|") {
+			t.Fatalf("events %+v %+v, sent %q; want Disconnected Reconnected, %q", disconnected, reconnected, sent, expect)
+		}
+	})
+
+	t.Run("should close events when connection is lost without reconnect", func(t *testing.T) {
+		s := newFakeServer(t, func(n int, conn net.Conn, line string) {
+			conn.Close()
+		})
+		c, _ := Dial(s.ln.Addr().String())
+
+		err := c.Login("alice", "")
+		nextEvent(t, c)
+		_, open := <-c.Events()
+
+		if err == nil || open {
+			t.Fatalf("Login() = %v, events open %v; want error and closed", err, open)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package client
+
+// Event is something the server told the client without being asked. It is
+// one of RoomMessage, UserMessage, Notice, Disconnected or Reconnected.
+type Event interface {
+	event()
+}
+
+// RoomMessage is a message sent to a chatroom the client is in.
+type RoomMessage struct {
+	From string
+	Room string
+	Text string
+}
+
+// UserMessage is a message sent directly to the client.
+type UserMessage struct {
+	From string
+	Text string
+}
+
+// Notice is any other line from the server, such as QUIT, KICKED, INVITED or
+// WALL. Command is the first word of the line and Args holds the rest split on
+// spaces. Line is the whole line without its CRLF.
+type Notice struct {
+	Command string
+	Args    []string
+	Line    string
+}
+
+// Disconnected is sent when the connection to the server is lost. When
+// reconnecting is enabled it is followed by Reconnected once the client is
+// back.
+type Disconnected struct {
+	Err error
+}
+
+// Reconnected is sent when the client has connected again, logged back in and
+// rejoined its chatrooms. Rooms that could not be rejoined are in Failed.
+type Reconnected struct {
+	Failed []string
+}
+
+func (RoomMessage) event()  {}
+func (UserMessage) event()  {}
+func (Notice) event()       {}
+func (Disconnected) event() {}
+func (Reconnected) event()  {}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/client"
+)
+
+// config holds the command line settings of a run.
+type config struct {
+	addr        string
+	clients     int
+	rooms       int
+	topology    string
+	rate        float64
+	duration    time.Duration
+	drain       time.Duration
+	size        int
+	prefix      string
+	concurrency int
+}
+
+// bench is a simulated client.
+type bench struct {
+	id    int
+	name  string
+	c     *client.Client
+	rooms []string
+	stats stats
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.addr, "addr", "localhost:6667", "server address")
+	flag.IntVar(&cfg.clients, "clients", 100, "number of simulated clients")
+	flag.IntVar(&cfg.rooms, "rooms", 10, "number of chatrooms")
+	flag.StringVar(&cfg.topology, "topology", "spread", "how clients join chatrooms: spread, single, mesh or direct")
+	flag.Float64Var(&cfg.rate, "rate", 1, "messages per second sent by each client, 0 for as fast as possible")
+	flag.DurationVar(&cfg.duration, "duration", 10*time.Second, "how long to send messages for")
+	flag.DurationVar(&cfg.drain, "drain", 2*time.Second, "how long to wait for messages still being delivered")
+	flag.IntVar(&cfg.size, "size", 64, "length of each message in bytes")
+	flag.StringVar(&cfg.prefix, "prefix", "bench", "prefix of the names clients log in with")
+	flag.IntVar(&cfg.concurrency, "concurrency", 50, "most clients connecting at once")
+	flag.Parse()
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "waddle-bench:", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var total stats
+	benches := connect(&cfg, &total)
+	if len(benches) == 0 {
+		log.Fatalln("no clients connected")
+	}
+	if cfg.topology == "direct" && len(benches) < 2 {
+		log.Fatalln("-topology direct needs at least 2 clients connected, got", len(benches))
+	}
+	fmt.Printf("%d of %d clients connected, sending for %v\n", len(benches), cfg.clients, cfg.duration)
+
+	var received sync.WaitGroup
+	for _, b := range benches {
+		received.Add(1)
+		go func(b *bench) {
+			defer received.Done()
+			b.receive()
+		}(b)
+	}
+
+	members := roomMembers(benches)
+	start := time.Now()
+	stop := time.After(cfg.duration)
+	done := make(chan struct{})
+	var sent sync.WaitGroup
+	for _, b := range benches {
+		sent.Add(1)
+		go func(b *bench) {
+			defer sent.Done()
+			b.send(&cfg, benches, members, done)
+		}(b)
+	}
+
+	<-stop
+	close(done)
+	sent.Wait()
+	elapsed := time.Since(start)
+
+	time.Sleep(cfg.drain)
+	for _, b := range benches {
+		b.c.Close()
+	}
+	received.Wait()
+
+	for _, b := range benches {
+		total.merge(&b.stats)
+	}
+	total.report(os.Stdout, &cfg, len(benches), elapsed)
+}
+
+func (cfg *config) validate() error {
+	switch {
+	case cfg.clients < 1:
+		return fmt.Errorf("-clients must be at least 1")
+	case cfg.rooms < 1 && cfg.topology != "direct":
+		return fmt.Errorf("-rooms must be at least 1")
+	case cfg.topology == "direct" && cfg.clients < 2:
+		return fmt.Errorf("-topology direct needs at least 2 clients")
+	case cfg.rate < 0:
+		return fmt.Errorf("-rate must not be negative")
+	case cfg.size < 32:
+		return fmt.Errorf("-size must be at least 32")
+	}
+
+	switch cfg.topology {
+	case "spread", "single", "mesh", "direct":
+		return nil
+	}
+	return fmt.Errorf("unknown topology %q", cfg.topology)
+}
+
+// roomsOf returns the chatrooms client i joins.
+func (cfg *config) roomsOf(i int) []string {
+	switch cfg.topology {
+	case "spread":
+		return []string{roomName(i % cfg.rooms)}
+	case "single":
+		return []string{roomName(0)}
+	case "mesh":
+		rooms := make([]string, cfg.rooms)
+		for r := range rooms {
+			rooms[r] = roomName(r)
+		}
+		return rooms
+	}
+	return nil
+}
+
+func roomName(i int) string {
+	return "#bench" + strconv.Itoa(i)
+}
+
+// connect connects, logs in and joins the chatrooms of each client. Clients
+// that fail are counted in total and left out.
+func connect(cfg *config, total *stats) []*bench {
+	var (
+		mu      sync.Mutex
+		benches []*bench
+		wg      sync.WaitGroup
+	)
+	slots := make(chan struct{}, cfg.concurrency)
+
+	for i := 0; i < cfg.clients; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			b := &bench{id: i, name: cfg.prefix + strconv.Itoa(i)}
+			if err := b.setup(cfg.addr, cfg.roomsOf(i)); err != nil {
+				total.fail("setup: " + err.Error())
+				return
+			}
+
+			mu.Lock()
+			benches = append(benches, b)
+			mu.Unlock()
+		}(i)
+	}
+	wg.Wait()
+
+	return benches
+}
+
+func (b *bench) setup(addr string, rooms []string) error {
+	c, err := client.Dial(addr)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Login(b.name, ""); err != nil {
+		c.Close()
+		return err
+	}
+
+	for _, room := range rooms {
+		if err := c.Join(room); err != nil {
+			c.Close()
+			return err
+		}
+	}
+
+	b.c = c
+	b.rooms = rooms
+
+	return nil
+}
+
+// roomMembers counts the connected clients in each chatroom.
+func roomMembers(benches []*bench) map[string]int64 {
+	members := make(map[string]int64)
+	for _, b := range benches {
+		for _, room := range b.rooms {
+			members[room]++
+		}
+	}
+	return members
+}
+
+// send sends messages at the configured rate until done is closed. Each
+// message starts with the time it was sent so receivers can measure how long
+// delivery took.
+func (b *bench) send(cfg *config, benches []*bench, members map[string]int64, done <-chan struct{}) {
+	var tick <-chan time.Time
+	if cfg.rate > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.rate))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	rnd := rand.New(rand.NewSource(int64(b.id)))
+	padding := strings.Repeat("x", cfg.size)
+
+	for seq := 0; ; seq++ {
+		if tick != nil {
+			select {
+			case <-done:
+				return
+			case <-tick:
+			}
+		} else {
+			select {
+			case <-done:
+				return
+			default:
+			}
+		}
+
+		var to string
+		var expect int64
+		if len(b.rooms) > 0 {
+			to = b.rooms[seq%len(b.rooms)]
+			expect = members[to]
+		} else {
+			other := benches[rnd.Intn(len(benches)-1)]
+			if other == b {
+				other = benches[len(benches)-1]
+			}
+			to = other.name
+			expect = 1
+		}
+
+		text := strconv.FormatInt(time.Now().UnixNano(), 10) + " " + strconv.Itoa(seq) + " "
+		text += padding[:cfg.size-len(text)]
+
+		if err := b.c.Msg(to, text); err != nil {
+			b.stats.fail("msg: " + err.Error())
+			continue
+		}
+		b.stats.send(expect)
+	}
+}
+
+// receive records the delivery latency of each message until the client is
+// closed.
+func (b *bench) receive() {
+	for e := range b.c.Events() {
+		var text string
+		switch e := e.(type) {
+		case client.RoomMessage:
+			text = e.Text
+		case client.UserMessage:
+			text = e.Text
+		case client.Disconnected:
+			if e.Err != nil {
+				b.stats.fail("disconnected: " + e.Err.Error())
+			}
+			continue
+		default:
+			continue
+		}
+
+		sentAt, err := strconv.ParseInt(strings.SplitN(text, " ", 2)[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		b.stats.deliver(time.Since(time.Unix(0, sentAt)))
+	}
+}
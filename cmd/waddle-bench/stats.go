@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stats counts what happened to the messages of one client, or of all of them
+// once merged.
+type stats struct {
+	mu        sync.Mutex
+	sent      int64
+	expected  int64
+	latencies []time.Duration
+	errors    map[string]int64
+}
+
+// send counts a message the server accepted, which expect clients should
+// receive.
+func (s *stats) send(expect int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent++
+	s.expected += expect
+}
+
+// deliver records a message received latency after it was sent.
+func (s *stats) deliver(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latencies = append(s.latencies, latency)
+}
+
+// fail counts an error by its reason.
+func (s *stats) fail(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.errors == nil {
+		s.errors = make(map[string]int64)
+	}
+	s.errors[reason]++
+}
+
+func (s *stats) merge(other *stats) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent += other.sent
+	s.expected += other.expected
+	s.latencies = append(s.latencies, other.latencies...)
+	for reason, n := range other.errors {
+		if s.errors == nil {
+			s.errors = make(map[string]int64)
+		}
+		s.errors[reason] += n
+	}
+}
+
+// report writes the throughput, latency percentiles and errors of a run that
+// sent messages for elapsed.
+func (s *stats) report(w io.Writer, cfg *config, connected int, elapsed time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seconds := elapsed.Seconds()
+	delivered := int64(len(s.latencies))
+
+	fmt.Fprintf(w, "clients      %d connected of %d\n", connected, cfg.clients)
+	if cfg.topology == "direct" {
+		fmt.Fprintf(w, "topology     direct\n")
+	} else {
+		fmt.Fprintf(w, "topology     %v, %d chatrooms\n", cfg.topology, cfg.rooms)
+	}
+	fmt.Fprintf(w, "duration     %v\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "sent         %d (%.1f/s)\n", s.sent, float64(s.sent)/seconds)
+	fmt.Fprintf(w, "delivered    %d of %d expected (%.1f/s)\n", delivered, s.expected, float64(delivered)/seconds)
+
+	if delivered > 0 {
+		sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+		fmt.Fprintf(w, "latency      p50 %v  p90 %v  p99 %v  max %v\n",
+			percentile(s.latencies, 50), percentile(s.latencies, 90),
+			percentile(s.latencies, 99), percentile(s.latencies, 100))
+	}
+
+	var failed int64
+	reasons := make([]string, 0, len(s.errors))
+	for reason, n := range s.errors {
+		failed += n
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	fmt.Fprintf(w, "errors       %d\n", failed)
+	for _, reason := range reasons {
+		fmt.Fprintf(w, "  %6d  %v\n", s.errors[reason], reason)
+	}
+}
+
+// percentile returns the p-th percentile of the sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	i := (len(sorted)*p+99)/100 - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i].Round(time.Microsecond)
+}
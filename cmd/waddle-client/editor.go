@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"unicode"
+)
+
+// key is a single key press. Printable keys are their rune, and other keys are
+// the negative constants below.
+type key rune
+
+const (
+	keyUnknown key = -(iota + 1)
+	keyEnter
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyUp
+	keyDown
+	keyHome
+	keyEnd
+	keyKillLine
+	keyKillEnd
+	keyKillWord
+	keyNextBuffer
+	keyPrevBuffer
+	keyInterrupt
+	keyEOF
+)
+
+// readKeys decodes key presses from the terminal and sends them on the
+// channel until reading fails.
+func readKeys(r io.Reader, keys chan<- key) {
+	defer close(keys)
+
+	br := bufio.NewReader(r)
+	for {
+		ch, _, err := br.ReadRune()
+		if err != nil {
+			return
+		}
+
+		switch ch {
+		case '\r', '\n':
+			keys <- keyEnter
+		case 0x7f, 0x08:
+			keys <- keyBackspace
+		case 0x01:
+			keys <- keyHome
+		case 0x05:
+			keys <- keyEnd
+		case 0x02:
+			keys <- keyLeft
+		case 0x06:
+			keys <- keyRight
+		case 0x0b:
+			keys <- keyKillEnd
+		case 0x15:
+			keys <- keyKillLine
+		case 0x17:
+			keys <- keyKillWord
+		case 0x0e:
+			keys <- keyNextBuffer
+		case 0x10:
+			keys <- keyPrevBuffer
+		case 0x03:
+			keys <- keyInterrupt
+		case 0x04:
+			keys <- keyEOF
+		case 0x1b:
+			keys <- readEscape(br)
+		default:
+			if unicode.IsPrint(ch) {
+				keys <- key(ch)
+			} else {
+				keys <- keyUnknown
+			}
+		}
+	}
+}
+
+// readEscape decodes the rest of an escape sequence such as "\x1b[A".
+func readEscape(br *bufio.Reader) key {
+	b, err := br.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return keyUnknown
+	}
+
+	var params []byte
+	for {
+		b, err = br.ReadByte()
+		if err != nil {
+			return keyUnknown
+		}
+		if b < '0' || b > '9' {
+			break
+		}
+		params = append(params, b)
+	}
+
+	switch b {
+	case 'A':
+		return keyUp
+	case 'B':
+		return keyDown
+	case 'C':
+		return keyRight
+	case 'D':
+		return keyLeft
+	case 'H':
+		return keyHome
+	case 'F':
+		return keyEnd
+	case '~':
+		switch string(params) {
+		case "1", "7":
+			return keyHome
+		case "3":
+			return keyDelete
+		case "4", "8":
+			return keyEnd
+		}
+	}
+
+	return keyUnknown
+}
+
+// editor is the line being typed along with the history of lines entered.
+type editor struct {
+	line    []rune
+	pos     int
+	history []string
+	// hist is the position in history being shown, len(history) when editing
+	// a new line.
+	hist  int
+	draft []rune
+}
+
+// maxHistory is how many entered lines are remembered.
+const maxHistory = 500
+
+// press applies the key to the line. Returns the line and true when the key
+// was enter.
+func (e *editor) press(k key) (string, bool) {
+	switch k {
+	case keyEnter:
+		line := string(e.line)
+		if line != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != line) {
+			e.history = append(e.history, line)
+			if len(e.history) > maxHistory {
+				e.history = e.history[1:]
+			}
+		}
+		e.line, e.pos, e.hist, e.draft = nil, 0, len(e.history), nil
+		return line, true
+	case keyBackspace:
+		if e.pos > 0 {
+			e.line = append(e.line[:e.pos-1], e.line[e.pos:]...)
+			e.pos--
+		}
+	case keyDelete:
+		if e.pos < len(e.line) {
+			e.line = append(e.line[:e.pos], e.line[e.pos+1:]...)
+		}
+	case keyLeft:
+		if e.pos > 0 {
+			e.pos--
+		}
+	case keyRight:
+		if e.pos < len(e.line) {
+			e.pos++
+		}
+	case keyHome:
+		e.pos = 0
+	case keyEnd:
+		e.pos = len(e.line)
+	case keyKillLine:
+		e.line = append([]rune(nil), e.line[e.pos:]...)
+		e.pos = 0
+	case keyKillEnd:
+		e.line = e.line[:e.pos]
+	case keyKillWord:
+		start := e.pos
+		for start > 0 && e.line[start-1] == ' ' {
+			start--
+		}
+		for start > 0 && e.line[start-1] != ' ' {
+			start--
+		}
+		e.line = append(e.line[:start], e.line[e.pos:]...)
+		e.pos = start
+	case keyUp:
+		if e.hist > 0 {
+			if e.hist == len(e.history) {
+				e.draft = e.line
+			}
+			e.hist--
+			e.show([]rune(e.history[e.hist]))
+		}
+	case keyDown:
+		if e.hist < len(e.history) {
+			e.hist++
+			if e.hist == len(e.history) {
+				e.show(e.draft)
+			} else {
+				e.show([]rune(e.history[e.hist]))
+			}
+		}
+	default:
+		if k >= 0 {
+			e.line = append(e.line, 0)
+			copy(e.line[e.pos+1:], e.line[e.pos:])
+			e.line[e.pos] = rune(k)
+			e.pos++
+		}
+	}
+
+	return "", false
+}
+
+// show replaces the line with a copy of the given one and moves to its end.
+func (e *editor) show(line []rune) {
+	e.line = append([]rune(nil), line...)
+	e.pos = len(e.line)
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ccassise/waddle/client"
+)
+
+// app connects the terminal to the server.
+type app struct {
+	c  *client.Client
+	ui *ui
+}
+
+func main() {
+	addr := flag.String("addr", "localhost:6667", "server address, or path of a Unix domain socket")
+	name := flag.String("name", "", "name to log in as")
+	password := flag.String("password", "", "password for servers that need one")
+	useTLS := flag.Bool("tls", false, "connect with TLS")
+	insecure := flag.Bool("insecure", false, "do not verify the server's TLS certificate")
+	noColor := flag.Bool("no-color", false, "do not color output")
+	flag.Parse()
+
+	dial := func() (net.Conn, error) {
+		network := "tcp"
+		if strings.Contains(*addr, "/") {
+			network = "unix"
+		}
+
+		conn, err := net.DialTimeout(network, *addr, 10*time.Second)
+		if err != nil || !*useTLS {
+			return conn, err
+		}
+
+		host, _, _ := net.SplitHostPort(*addr)
+		return tls.Client(conn, &tls.Config{ServerName: host, InsecureSkipVerify: *insecure}), nil
+	}
+
+	c, err := client.DialWithOptions(*addr, client.Options{Dial: dial, Reconnect: true})
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	fd := int(os.Stdin.Fd())
+	raw := isTerminal(fd) && isTerminal(int(os.Stdout.Fd()))
+	if raw {
+		restore, err := makeRaw(fd)
+		if err != nil {
+			raw = false
+		} else {
+			defer restore()
+		}
+	}
+
+	a := &app{
+		c:  c,
+		ui: newUI(os.Stdout, raw, raw && !*noColor),
+	}
+	a.ui.notice(statusBuffer, "connected to "+*addr+", type /help for commands")
+
+	if *name != "" {
+		a.login(*name, *password)
+	}
+
+	a.run(raw)
+	a.ui.clear()
+}
+
+// run handles typed lines and server events until the user quits or the
+// connection is closed.
+func (a *app) run(raw bool) {
+	var keys chan key
+	var lines chan string
+	if raw {
+		keys = make(chan key)
+		go readKeys(os.Stdin, keys)
+	} else {
+		lines = make(chan string)
+		go readLines(lines)
+	}
+
+	a.ui.redraw()
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok || k == keyInterrupt || (k == keyEOF && len(a.ui.ed.line) == 0) {
+				a.quit()
+				return
+			}
+
+			switch k {
+			case keyNextBuffer:
+				a.ui.cycle(1)
+				continue
+			case keyPrevBuffer:
+				a.ui.cycle(-1)
+				continue
+			}
+
+			line, entered := a.ui.ed.press(k)
+			if entered && a.submit(line) {
+				return
+			}
+			a.ui.redraw()
+		case line, ok := <-lines:
+			if !ok || a.submit(line) {
+				a.quit()
+				return
+			}
+		case e, ok := <-a.c.Events():
+			if !ok {
+				return
+			}
+			a.event(e)
+		}
+	}
+}
+
+// readLines sends each line of standard input on the channel.
+func readLines(lines chan<- string) {
+	defer close(lines)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		lines <- scanner.Text()
+	}
+}
+
+// submit acts on an entered line. Returns true when the client should exit.
+func (a *app) submit(line string) bool {
+	if strings.TrimSpace(line) == "" {
+		return false
+	}
+
+	if !strings.HasPrefix(line, "/") || strings.HasPrefix(line, "//") {
+		text := strings.TrimPrefix(line, "/")
+		if a.ui.active == statusBuffer {
+			a.ui.notice(statusBuffer, "not in a chatroom or conversation, use /join #room or /msg <user> <text>")
+			return false
+		}
+		a.send(a.ui.active, text)
+		return false
+	}
+
+	fields := strings.Fields(line)
+	command, args := strings.ToLower(fields[0]), fields[1:]
+	rest := ""
+	if len(fields) > 1 {
+		rest = strings.TrimSpace(line[len(fields[0]):])
+	}
+
+	switch command {
+	case "/join", "/j":
+		if len(args) < 1 {
+			return a.usage("/join #<chatroom> [key]")
+		}
+		a.join(args[0], args[1:])
+	case "/part", "/p":
+		room := a.ui.active
+		if len(args) > 0 {
+			room = args[0]
+		}
+		if !strings.HasPrefix(room, "#") {
+			return a.usage("/part [#<chatroom>]")
+		}
+		if a.check(a.c.Part(room)) {
+			a.ui.close(room)
+		}
+	case "/msg", "/m":
+		if len(args) < 2 {
+			return a.usage("/msg <user|#chatroom> <text>")
+		}
+		a.send(args[0], strings.TrimSpace(rest[len(args[0]):]))
+	case "/query", "/q":
+		if len(args) != 1 {
+			return a.usage("/query <user>")
+		}
+		a.ui.switchTo(args[0])
+	case "/nick", "/login":
+		if len(args) < 1 || len(args) > 2 {
+			return a.usage(command + " <name> [password]")
+		}
+		if a.c.Name() != "" {
+			a.ui.notice(a.ui.active, "already logged in as "+a.c.Name()+", the server does not support changing names")
+			return false
+		}
+		password := ""
+		if len(args) > 1 {
+			password = args[1]
+		}
+		a.login(args[0], password)
+	case "/buffer", "/b":
+		if len(args) != 1 {
+			return a.usage("/buffer <name>")
+		}
+		if !a.ui.has(args[0]) {
+			a.ui.notice(a.ui.active, "no buffer named "+args[0])
+			return false
+		}
+		a.ui.switchTo(args[0])
+	case "/next":
+		a.ui.cycle(1)
+	case "/prev":
+		a.ui.cycle(-1)
+	case "/close":
+		name := a.ui.active
+		if strings.HasPrefix(name, "#") && !a.check(a.c.Part(name)) {
+			return false
+		}
+		a.ui.close(name)
+	case "/list":
+		a.command("LIST")
+	case "/raw", "/quote":
+		if rest == "" {
+			return a.usage("/raw <command line>")
+		}
+		a.command(rest)
+	case "/quit", "/exit":
+		a.quit()
+		return true
+	case "/help", "/h":
+		a.help()
+	default:
+		a.ui.notice(a.ui.active, "unknown command "+command+", type /help for commands")
+	}
+
+	return false
+}
+
+func (a *app) login(name string, password string) {
+	if a.check(a.c.Login(name, password)) {
+		a.ui.notice(statusBuffer, "logged in as "+name)
+	}
+}
+
+func (a *app) join(room string, key []string) {
+	var err error
+	if len(key) > 0 {
+		// Keyed chatrooms are joined directly, so they are not rejoined
+		// after reconnecting.
+		_, err = a.c.Command("JOIN " + room + " " + key[0])
+	} else {
+		err = a.c.Join(room)
+	}
+
+	if a.check(err) {
+		a.ui.switchTo(room)
+		a.ui.notice(room, "joined "+room)
+	}
+}
+
+// send sends the text to a chatroom or user. Chatroom messages come back from
+// the server, but direct messages are shown here.
+func (a *app) send(to string, text string) {
+	if !a.check(a.c.Msg(to, text)) {
+		return
+	}
+
+	if !strings.HasPrefix(to, "#") {
+		a.ui.message(to, a.c.Name(), text)
+		if a.ui.active != to {
+			a.ui.switchTo(to)
+		}
+	}
+}
+
+// command sends a command line and shows the lines it was answered with.
+func (a *app) command(line string) {
+	lines, err := a.c.Command(line)
+	if !a.check(err) {
+		return
+	}
+
+	for _, l := range lines {
+		a.ui.notice(a.ui.active, l)
+	}
+}
+
+func (a *app) quit() {
+	if a.c.Name() != "" {
+		a.c.Logout()
+	}
+	a.c.Close()
+}
+
+// check shows the error, if any, in the active buffer. Returns whether there
+// was no error.
+func (a *app) check(err error) bool {
+	if err != nil {
+		a.ui.notice(a.ui.active, "error: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func (a *app) usage(text string) bool {
+	a.ui.notice(a.ui.active, "usage: "+text)
+	return false
+}
+
+// event shows something the server sent in the buffer it belongs to.
+func (a *app) event(e client.Event) {
+	switch e := e.(type) {
+	case client.RoomMessage:
+		a.ui.message(e.Room, e.From, e.Text)
+	case client.UserMessage:
+		a.ui.message(e.From, e.From, e.Text)
+	case client.Notice:
+		name := statusBuffer
+		for _, arg := range e.Args {
+			if strings.HasPrefix(arg, "#") && a.ui.has(arg) {
+				name = arg
+				break
+			}
+		}
+		a.ui.notice(name, e.Line)
+		if e.Command == "KICKED" || e.Command == "CLOSED" || e.Command == "RENAMED" {
+			a.syncRooms()
+		}
+	case client.Disconnected:
+		a.ui.notice(statusBuffer, fmt.Sprintf("disconnected: %v, reconnecting", e.Err))
+	case client.Reconnected:
+		a.ui.notice(statusBuffer, "reconnected")
+		for _, room := range e.Failed {
+			a.ui.notice(statusBuffer, "could not rejoin "+room)
+		}
+		a.syncRooms()
+	}
+}
+
+// syncRooms opens a buffer for each chatroom the client is in, such as the new
+// name of a renamed chatroom.
+func (a *app) syncRooms() {
+	for _, room := range a.c.Rooms() {
+		a.ui.buffer(room)
+	}
+	a.ui.redraw()
+}
+
+func (a *app) help() {
+	for _, line := range []string{
+		"/join #<chatroom> [key]       join a chatroom",
+		"/part [#<chatroom>]           leave a chatroom",
+		"/msg <user|#chatroom> <text>  send a message",
+		"/query <user>                 open a conversation with a user",
+		"/nick <name> [password]       log in as name",
+		"/buffer <name>                switch to a buffer, also Ctrl-N and Ctrl-P",
+		"/close                        close the buffer, leaving its chatroom",
+		"/list                         list chatrooms",
+		"/raw <command line>           send a protocol command",
+		"/quit                         log out and exit",
+		"Text not starting with / is sent to the active chatroom or user.",
+	} {
+		a.ui.notice(a.ui.active, line)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"strings"
+	"time"
+)
+
+// statusBuffer holds server notices and anything not about a chatroom or
+// user.
+const statusBuffer = "*status*"
+
+// maxLines is how many lines each buffer keeps.
+const maxLines = 1000
+
+// senderColors are the ANSI colors sender names are drawn in.
+var senderColors = []int{31, 32, 33, 34, 35, 36, 91, 92, 93, 94, 95, 96}
+
+// buffer is the scrollback of a chatroom, a conversation with a user, or the
+// status buffer.
+type buffer struct {
+	name   string
+	lines  []string
+	unread int
+}
+
+// ui draws buffers and the line being typed. When raw is set the terminal is
+// in raw mode and the prompt is redrawn below incoming lines; otherwise lines
+// are written as they come, prefixed with their buffer when it is not the
+// active one.
+type ui struct {
+	out     io.Writer
+	raw     bool
+	color   bool
+	ed      *editor
+	buffers map[string]*buffer
+	order   []string
+	active  string
+	now     func() time.Time
+}
+
+func newUI(out io.Writer, raw bool, color bool) *ui {
+	u := &ui{
+		out:     out,
+		raw:     raw,
+		color:   color,
+		ed:      &editor{},
+		buffers: make(map[string]*buffer),
+		now:     time.Now,
+	}
+	u.buffer(statusBuffer)
+	u.active = statusBuffer
+
+	return u
+}
+
+// buffer returns the buffer with the given name, creating it if needed.
+func (u *ui) buffer(name string) *buffer {
+	b, ok := u.buffers[name]
+	if !ok {
+		b = &buffer{name: name}
+		u.buffers[name] = b
+		u.order = append(u.order, name)
+	}
+	return b
+}
+
+// has returns whether a buffer with the given name is open.
+func (u *ui) has(name string) bool {
+	_, ok := u.buffers[name]
+	return ok
+}
+
+// message adds a message from sender to the named buffer.
+func (u *ui) message(name string, sender string, text string) {
+	u.print(name, "<"+u.paint(sender)+"> "+text)
+}
+
+// notice adds a line from the server or the client itself to the named
+// buffer.
+func (u *ui) notice(name string, text string) {
+	u.print(name, u.dim("-!-")+" "+text)
+}
+
+// print adds a timestamped line to the named buffer, showing it when the
+// buffer is active.
+func (u *ui) print(name string, text string) {
+	b := u.buffer(name)
+	line := u.dim(u.now().Format("15:04")) + " " + text
+
+	b.lines = append(b.lines, line)
+	if len(b.lines) > maxLines {
+		b.lines = b.lines[len(b.lines)-maxLines:]
+	}
+
+	if !u.raw {
+		if name != u.active {
+			line = "[" + name + "] " + line
+		}
+		fmt.Fprintln(u.out, line)
+		return
+	}
+
+	if name != u.active {
+		b.unread++
+		u.redraw()
+		return
+	}
+
+	fmt.Fprint(u.out, "\r\x1b[K"+line+"\n")
+	u.redraw()
+}
+
+// switchTo makes the named buffer active and shows its scrollback.
+func (u *ui) switchTo(name string) {
+	b := u.buffer(name)
+	u.active = name
+	b.unread = 0
+
+	if !u.raw {
+		fmt.Fprintln(u.out, "-- "+name+" --")
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[2J\x1b[H")
+	for _, line := range b.lines {
+		buf.WriteString(line)
+		buf.WriteString("\n")
+	}
+	u.out.Write(buf.Bytes())
+	u.redraw()
+}
+
+// cycle switches to the buffer step places after the active one, wrapping
+// around.
+func (u *ui) cycle(step int) {
+	for i, name := range u.order {
+		if name == u.active {
+			next := (i + step + len(u.order)) % len(u.order)
+			u.switchTo(u.order[next])
+			return
+		}
+	}
+}
+
+// close removes the named buffer, switching to the status buffer if it was
+// active. The status buffer cannot be closed.
+func (u *ui) close(name string) {
+	if name == statusBuffer || !u.has(name) {
+		return
+	}
+
+	delete(u.buffers, name)
+	for i := range u.order {
+		if u.order[i] == name {
+			u.order = append(u.order[:i], u.order[i+1:]...)
+			break
+		}
+	}
+
+	if u.active == name {
+		u.switchTo(statusBuffer)
+	} else {
+		u.redraw()
+	}
+}
+
+// redraw draws the prompt and the line being typed.
+func (u *ui) redraw() {
+	if !u.raw {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("\r\x1b[K")
+	buf.WriteString(u.prompt())
+	buf.WriteString(string(u.ed.line))
+	if back := len(u.ed.line) - u.ed.pos; back > 0 {
+		fmt.Fprintf(&buf, "\x1b[%dD", back)
+	}
+	u.out.Write(buf.Bytes())
+}
+
+// prompt returns the name of the active buffer followed by the buffers with
+// unread lines.
+func (u *ui) prompt() string {
+	var activity []string
+	for _, name := range u.order {
+		if b := u.buffers[name]; b.unread > 0 {
+			activity = append(activity, fmt.Sprintf("%v:%d", name, b.unread))
+		}
+	}
+
+	p := "[" + u.active + "] "
+	if len(activity) > 0 {
+		p += "(" + strings.Join(activity, " ") + ") "
+	}
+	return p + "> "
+}
+
+// clear erases the prompt so the terminal is left tidy on exit.
+func (u *ui) clear() {
+	if u.raw {
+		fmt.Fprint(u.out, "\r\x1b[K")
+	}
+}
+
+// paint draws the name in a color picked from its hash, so each sender keeps
+// the same color.
+func (u *ui) paint(name string) string {
+	if !u.color {
+		return name
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	c := senderColors[h.Sum32()%uint32(len(senderColors))]
+
+	return fmt.Sprintf("\x1b[%dm%v\x1b[0m", c, name)
+}
+
+func (u *ui) dim(s string) string {
+	if !u.color {
+		return s
+	}
+	return "\x1b[2m" + s + "\x1b[0m"
+}
@@ -0,0 +1,9 @@
+package main
+
+// Plugins register themselves when imported. Add an import here to make a
+// plugin available to this build of the server, then enable it by name in the
+// configuration's plugins. Plugins kept outside this repository are built into
+// a main package of their own instead, as shown in package server.
+import (
+	_ "github.com/ccassise/waddle/plugin/seen"
+)
@@ -0,0 +1,256 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Entry is one audited event.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Action  string    `json:"action"`
+	User    string    `json:"user,omitempty"`
+	Address string    `json:"address,omitempty"`
+	Args    []string  `json:"args,omitempty"`
+}
+
+// Log appends entries to a file as JSON lines, starting a new file once the
+// current one reaches its maximum size, and keeps the most recent entries in
+// memory. The file is written by a goroutine of its own so that recording an
+// entry never waits on the disk.
+type Log struct {
+	mu     sync.Mutex
+	cfg    config.Audit
+	recent []Entry
+	next   int
+	full   bool
+	closed bool
+
+	// lines holds the entries waiting to be written, and is closed once the
+	// log is. done is closed when the writer has written them all.
+	lines chan []byte
+	done  chan struct{}
+
+	// file and size are only used by the writer.
+	file *os.File
+	size int64
+
+	// errMu guards err, the last error the writer ran into. lost is whether
+	// the file could not be reopened, in which case err is kept and every
+	// later entry fails.
+	errMu sync.Mutex
+	err   error
+	lost  bool
+}
+
+// Open opens the audit log described by the configuration. When no file is
+// given entries are only kept in memory.
+func Open(cfg config.Audit) (*Log, error) {
+	l := &Log{cfg: cfg}
+	if cfg.Recent > 0 {
+		l.recent = make([]Entry, cfg.Recent)
+	}
+
+	if cfg.File == "" {
+		return l, nil
+	}
+
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+
+	l.lines = make(chan []byte, queueSize)
+	l.done = make(chan struct{})
+	go l.run()
+
+	return l, nil
+}
+
+// Record appends the entry to the log. The error, if any, is from writing an
+// earlier entry, since the entry itself is written later.
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return errors.New(errClosed)
+	}
+
+	if len(l.recent) > 0 {
+		l.recent[l.next] = e
+		l.next = (l.next + 1) % len(l.recent)
+		if l.next == 0 {
+			l.full = true
+		}
+	}
+
+	if l.lines == nil {
+		return nil
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.lines <- append(b, '\n')
+
+	return l.writeErr()
+}
+
+// Recent returns up to n of the latest entries, oldest first.
+func (l *Log) Recent(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.recent)
+	}
+	if n > count {
+		n = count
+	}
+
+	entries := make([]Entry, n)
+	for i := range entries {
+		j := (l.next - n + i + len(l.recent)) % len(l.recent)
+		entries[i] = l.recent[j]
+	}
+	return entries
+}
+
+// Close writes the entries still waiting and closes the log file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	if l.lines == nil {
+		return nil
+	}
+
+	close(l.lines)
+	<-l.done
+
+	if l.file == nil {
+		return l.writeErr()
+	}
+
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// run writes entries to the file until the log is closed.
+func (l *Log) run() {
+	defer close(l.done)
+
+	for b := range l.lines {
+		if err := l.write(b); err != nil {
+			l.errMu.Lock()
+			l.err = err
+			l.lost = l.file == nil
+			l.errMu.Unlock()
+		}
+	}
+}
+
+// writeErr returns the last error the writer ran into, forgetting it unless
+// the file has been lost.
+func (l *Log) writeErr() error {
+	l.errMu.Lock()
+	defer l.errMu.Unlock()
+
+	err := l.err
+	if !l.lost {
+		l.err = nil
+	}
+	return err
+}
+
+// write writes one line to the file, rotating it first when it is full.
+func (l *Log) write(b []byte) error {
+	if l.file == nil {
+		return errors.New(errFileLost)
+	}
+
+	var rotateErr error
+	if l.cfg.MaxSize > 0 && l.size > 0 && l.size+int64(len(b)) > l.cfg.MaxSize {
+		if rotateErr = l.rotate(); l.file == nil {
+			return rotateErr
+		}
+	}
+
+	n, err := l.file.Write(b)
+	l.size += int64(n)
+	if err != nil {
+		return err
+	}
+	return rotateErr
+}
+
+// open opens the log file for appending.
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.cfg.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	return nil
+}
+
+// rotate renames the log file to <file>.1, shifting older files up by one and
+// removing any past MaxFiles, and then opens a new log file. At least one old
+// file is always kept, so rotating never throws the whole trail away. When
+// renaming fails the file is reopened where it was and keeps growing; when it
+// cannot be opened at all l.file is left nil.
+func (l *Log) rotate() error {
+	l.file.Close()
+	l.file = nil
+
+	keep := l.cfg.MaxFiles
+	if keep < 1 {
+		keep = 1
+	}
+
+	os.Remove(rotated(l.cfg.File, keep))
+	for i := keep - 1; i >= 1; i-- {
+		os.Rename(rotated(l.cfg.File, i), rotated(l.cfg.File, i+1))
+	}
+	err := os.Rename(l.cfg.File, rotated(l.cfg.File, 1))
+
+	if openErr := l.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func rotated(path string, i int) string {
+	return fmt.Sprintf("%v.%d", path, i)
+}
+
+// queueSize is how many entries may wait to be written before Record waits
+// for the disk.
+const queueSize = 256
+
+const (
+	errClosed   = "audit log closed"
+	errFileLost = "audit log file could not be reopened"
+)
@@ -0,0 +1,127 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// readEntries returns the entries in the log file at the given path.
+func readEntries(t *testing.T, path string) []Entry {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("os.Open() = %v, want %v", err, nil)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("json.Unmarshal(%#q) = %v, want %v", scanner.Bytes(), err, nil)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestRecord(t *testing.T) {
+	t.Run("should append JSON lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		l, _ := Open(config.Audit{File: path})
+
+		l.Record(Entry{Time: time.Now(), Action: "LOGIN", User: "alice", Address: "127.0.0.1:5000"})
+		l.Record(Entry{Time: time.Now(), Action: "KICK", User: "alice", Args: []string{"#room", "bob"}})
+		l.Close()
+
+		entries := readEntries(t, path)
+		if len(entries) != 2 || entries[0].Action != "LOGIN" || entries[1].Args[1] != "bob" {
+			t.Fatalf("log = %+v, want LOGIN and KICK", entries)
+		}
+	})
+
+	t.Run("should rotate when file is full", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		l, _ := Open(config.Audit{File: path, MaxSize: 100, MaxFiles: 2})
+
+		for _, action := range []string{"ONE", "TWO", "THREE", "FOUR"} {
+			l.Record(Entry{Action: action, User: "alice", Address: "127.0.0.1:5000"})
+		}
+		l.Close()
+
+		current := readEntries(t, path)
+		older := readEntries(t, path+".1")
+		oldest := readEntries(t, path+".2")
+		_, err := os.Stat(path + ".3")
+		if len(current) != 1 || current[0].Action != "FOUR" || older[0].Action != "THREE" || oldest[0].Action != "TWO" || !os.IsNotExist(err) {
+			t.Fatalf("log = %+v %+v %+v, want FOUR THREE TWO and no fourth file", current, older, oldest)
+		}
+	})
+
+	t.Run("should keep writing to the file when it cannot be rotated", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "audit.log")
+		os.MkdirAll(filepath.Join(path+".1", "full"), 0700)
+		l, _ := Open(config.Audit{File: path, MaxSize: 100, MaxFiles: 1})
+
+		for _, action := range []string{"ONE", "TWO", "THREE"} {
+			l.Record(Entry{Action: action, User: "alice", Address: "127.0.0.1:5000"})
+		}
+		err := l.Close()
+
+		entries := readEntries(t, path)
+		if err != nil || len(entries) != 3 || entries[2].Action != "THREE" {
+			t.Fatalf("Close() = %v, want %v; log = %+v, want ONE TWO THREE", err, nil, entries)
+		}
+	})
+
+	t.Run("should fail every entry once the file is lost", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "audit")
+		os.Mkdir(dir, 0700)
+		l, _ := Open(config.Audit{File: filepath.Join(dir, "audit.log"), MaxSize: 100})
+		defer l.Close()
+
+		os.RemoveAll(dir)
+		var err error
+		for deadline := time.Now().Add(time.Second); err == nil && time.Now().Before(deadline); {
+			err = l.Record(Entry{Action: "ONE", User: "alice", Address: "127.0.0.1:5000"})
+		}
+		again := l.Record(Entry{Action: "TWO"})
+
+		if err == nil || again == nil {
+			t.Fatalf("Record() = %v %v, want errors", err, again)
+		}
+	})
+}
+
+func TestRecent(t *testing.T) {
+	t.Run("should return latest entries oldest first", func(t *testing.T) {
+		l, _ := Open(config.Audit{Recent: 3})
+
+		for _, action := range []string{"ONE", "TWO", "THREE", "FOUR"} {
+			l.Record(Entry{Action: action})
+		}
+		entries := l.Recent(10)
+
+		if len(entries) != 3 || entries[0].Action != "TWO" || entries[2].Action != "FOUR" {
+			t.Fatalf("Recent() = %+v, want TWO THREE FOUR", entries)
+		}
+	})
+
+	t.Run("should return at most n entries", func(t *testing.T) {
+		l, _ := Open(config.Audit{Recent: 10})
+
+		l.Record(Entry{Action: "ONE"})
+		l.Record(Entry{Action: "TWO"})
+		entries := l.Recent(1)
+
+		if len(entries) != 1 || entries[0].Action != "TWO" {
+			t.Fatalf("Recent() = %+v, want TWO", entries)
+		}
+	})
+}
@@ -0,0 +1,300 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+)
+
+// Config holds all of the server settings that can be read from a
+// configuration file. Any setting missing from the file keeps its default.
+type Config struct {
+	Listeners []Listener `json:"listeners"`
+	RateLimit RateLimit  `json:"rate_limit"`
+	Keepalive Keepalive  `json:"keepalive"`
+	Limits    Limits     `json:"limits"`
+	Proxy     Proxy      `json:"proxy"`
+	Filters   Filters    `json:"filters"`
+	Spam      Spam       `json:"spam"`
+	Audit     Audit      `json:"audit"`
+	Services  Services   `json:"services"`
+	Protocol  Protocol   `json:"protocol"`
+	Sanitize  Sanitize   `json:"sanitize"`
+
+	// Opers maps the name of each server operator account to its password,
+	// either in plain text or as "sha256:<hex>".
+	Opers map[string]string `json:"opers"`
+
+	// DataFile is the path of the JSON file registered accounts and account
+	// settings are saved in. Empty disables saving and registering.
+	DataFile string `json:"data_file"`
+
+	// Plugins names the registered plugins to enable.
+	Plugins []string `json:"plugins"`
+
+	// RejectIgnoredDMs tells senders when a direct message was not delivered
+	// because its receiver ignores them.
+	RejectIgnoredDMs bool `json:"reject_ignored_dms"`
+}
+
+// Listener is an address the server accepts connections on. Network is one of
+// "tcp", "tls", "ws" or "unix". TLS listeners, and WebSocket listeners when
+// given a certificate, use CertFile and KeyFile. WebSocket listeners upgrade
+// requests for Path. Unix domain sockets are created with the octal file Mode.
+// When Password is set, LOGIN on this listener must give it.
+type Listener struct {
+	Network  string `json:"network"`
+	Address  string `json:"address"`
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	Path     string `json:"path"`
+	Mode     string `json:"mode"`
+	Password string `json:"password"`
+}
+
+// RateLimit configures flood protection. Connection and Account map a command
+// class ("msg", "room" or "default") to the token bucket used for it.
+type RateLimit struct {
+	Enabled         bool            `json:"enabled"`
+	Connection      map[string]Rate `json:"connection"`
+	Account         map[string]Rate `json:"account"`
+	StrikeWindow    Duration        `json:"strike_window"`
+	MuteAfter       int             `json:"mute_after"`
+	MuteFor         Duration        `json:"mute_for"`
+	DisconnectAfter int             `json:"disconnect_after"`
+}
+
+// Keepalive configures when a quiet client is sent a PING and how long it has
+// to answer with a PONG. An Idle of zero disables keepalives.
+type Keepalive struct {
+	Idle    Duration `json:"idle"`
+	Timeout Duration `json:"timeout"`
+}
+
+// Limits caps how many connections and users the server accepts. A limit of
+// zero means unlimited. Connections from the same IPv4 or IPv6 network of the
+// given prefix length count as one source. When Allow is not empty only
+// sources in one of its CIDRs may connect, and sources in Deny never may.
+type Limits struct {
+	MaxConnections   int      `json:"max_connections"`
+	MaxPerSource     int      `json:"max_per_source"`
+	SourcePrefixIPv4 int      `json:"source_prefix_ipv4"`
+	SourcePrefixIPv6 int      `json:"source_prefix_ipv6"`
+	MaxUsers         int      `json:"max_users"`
+	Allow            []string `json:"allow"`
+	Deny             []string `json:"deny"`
+}
+
+// Proxy configures the PROXY protocol. Connections from a Trusted CIDR must
+// begin with a version 1 or 2 header within Timeout, and the client address in
+// it is used in place of the proxy's.
+type Proxy struct {
+	Trusted []string `json:"trusted"`
+	Timeout Duration `json:"timeout"`
+}
+
+// Filters configures the content filters messages pass through before they are
+// delivered. Default applies to direct messages and to every chatroom not
+// listed in Rooms, which replaces it for the chatrooms given.
+type Filters struct {
+	Default []Filter            `json:"default"`
+	Rooms   map[string][]Filter `json:"rooms"`
+}
+
+// Filter is one built in content filter. Type is one of:
+//
+//	"max_length" rejects messages longer than Max characters.
+//	"profanity"  masks each of Words with asterisks.
+//	"links"      rejects messages containing links.
+//	"regex"      applies Action to messages matching Pattern.
+//
+// Action is "reject" (the default), "drop", or "rewrite" which replaces each
+// match with Replace. Reason is the error rejected senders are sent.
+type Filter struct {
+	Type    string   `json:"type"`
+	Max     int      `json:"max"`
+	Words   []string `json:"words"`
+	Pattern string   `json:"pattern"`
+	Action  string   `json:"action"`
+	Replace string   `json:"replace"`
+	Reason  string   `json:"reason"`
+}
+
+// Spam configures duplicate message detection. A message is spam when its
+// sender sent the same text UserRepeats times within Window, or when
+// ServerRepeats different users did, across any chatrooms and users. Texts
+// compare equal ignoring case, spacing and punctuation, and texts shorter than
+// MinLength are never spam. Action is "reject", "mute" which also mutes the
+// sender for MuteFor, or "notify" which delivers the message and only tells
+// server operators.
+type Spam struct {
+	Enabled       bool     `json:"enabled"`
+	Window        Duration `json:"window"`
+	UserRepeats   int      `json:"user_repeats"`
+	ServerRepeats int      `json:"server_repeats"`
+	MinLength     int      `json:"min_length"`
+	Action        string   `json:"action"`
+	MuteFor       Duration `json:"mute_for"`
+}
+
+// Audit configures the log of moderation and account events. Entries are
+// appended to File as JSON lines. Once File reaches MaxSize bytes it is renamed
+// to File.1, older files are shifted up, and at most MaxFiles old files are
+// kept, so MaxFiles must be at least one unless MaxSize is zero, which never
+// rotates. The latest Recent entries are also kept in memory for the AUDIT
+// command. An empty File keeps entries in memory only.
+type Audit struct {
+	File     string `json:"file"`
+	MaxSize  int64  `json:"max_size"`
+	MaxFiles int    `json:"max_files"`
+	Recent   int    `json:"recent"`
+}
+
+// Services configures the users that live inside the server. Echo is the name
+// of a service that sends each direct message back to its sender. An empty name
+// disables the service.
+type Services struct {
+	Echo string `json:"echo"`
+}
+
+// Protocol configures how strictly request lines are read. Lines longer than
+// MaxLineLength bytes, counting the line ending, are discarded; zero means
+// unlimited. Strict requires lines to end in CRLF and rejects lines containing
+// any other CR or a NUL. Otherwise a lone LF also ends a line.
+type Protocol struct {
+	Strict        bool `json:"strict"`
+	MaxLineLength int  `json:"max_line_length"`
+}
+
+// Sanitize configures how the text of requests is cleaned before it is used.
+// InvalidUTF8 is "reject" to refuse requests that are not valid UTF-8 or
+// "repair" to replace invalid bytes with U+FFFD. Controls applies to C0 and C1
+// control characters other than tab, along with the ANSI escape sequences they
+// begin, and Bidi to bidirectional formatting characters. Each is "strip" to
+// remove them or "escape" to write them as text such as <U+001B>. Empty leaves
+// the text as it is.
+type Sanitize struct {
+	InvalidUTF8 string `json:"invalid_utf8"`
+	Controls    string `json:"controls"`
+	Bidi        string `json:"bidi"`
+}
+
+// Rate is the refill rate and burst size of a token bucket.
+type Rate struct {
+	PerSecond float64 `json:"per_second"`
+	Burst     int     `json:"burst"`
+}
+
+// Default returns the configuration used when no file is given.
+func Default() Config {
+	return Config{
+		RateLimit: RateLimit{
+			Enabled: true,
+			Connection: map[string]Rate{
+				"msg":     {PerSecond: 5, Burst: 20},
+				"room":    {PerSecond: 2, Burst: 10},
+				"default": {PerSecond: 5, Burst: 20},
+			},
+			Account: map[string]Rate{
+				"msg":     {PerSecond: 10, Burst: 40},
+				"room":    {PerSecond: 4, Burst: 20},
+				"default": {PerSecond: 10, Burst: 40},
+			},
+			StrikeWindow:    Duration(time.Minute),
+			MuteAfter:       10,
+			MuteFor:         Duration(time.Minute),
+			DisconnectAfter: 50,
+		},
+		Keepalive: Keepalive{
+			Idle:    Duration(2 * time.Minute),
+			Timeout: Duration(time.Minute),
+		},
+		Limits: Limits{
+			MaxConnections:   4096,
+			MaxPerSource:     16,
+			SourcePrefixIPv4: 32,
+			SourcePrefixIPv6: 64,
+		},
+		Proxy: Proxy{
+			Timeout: Duration(5 * time.Second),
+		},
+		Spam: Spam{
+			Window:        Duration(30 * time.Second),
+			UserRepeats:   3,
+			ServerRepeats: 5,
+			MinLength:     8,
+			Action:        "reject",
+			MuteFor:       Duration(5 * time.Minute),
+		},
+		Audit: Audit{
+			MaxSize:  10 << 20,
+			MaxFiles: 5,
+			Recent:   1000,
+		},
+		Protocol: Protocol{
+			MaxLineLength: 1024,
+		},
+		Sanitize: Sanitize{
+			InvalidUTF8: "repair",
+			Controls:    "strip",
+		},
+	}
+}
+
+// Load reads the JSON configuration file at the given path on top of the
+// default configuration.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&cfg); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Keepalive.Idle > 0 && cfg.Keepalive.Timeout <= 0 {
+		return Config{}, errors.New(errKeepaliveTimeout)
+	}
+
+	if cfg.Audit.MaxSize > 0 && cfg.Audit.MaxFiles < 1 {
+		return Config{}, errors.New(errAuditMaxFiles)
+	}
+
+	return cfg, nil
+}
+
+// Duration is a time.Duration that is written as a string such as "1m30s" in
+// the configuration file.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.New(errDuration)
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+const (
+	errAuditMaxFiles    = "audit max_files must be at least one when max_size is set"
+	errDuration         = "durations must be strings such as \"1m30s\""
+	errKeepaliveTimeout = "keepalive timeout must be above zero when idle is set"
+)
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "waddle.json")
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("should keep defaults for missing settings", func(t *testing.T) {
+		path := writeConfig(t, `{"rate_limit": {"mute_after": 3}}`)
+
+		cfg, err := Load(path)
+
+		if err != nil || cfg.RateLimit.MuteAfter != 3 || !cfg.RateLimit.Enabled {
+			t.Fatalf("Load() = (%+v, %v), want mute_after 3 and enabled", cfg.RateLimit, err)
+		}
+	})
+
+	t.Run("should parse durations", func(t *testing.T) {
+		path := writeConfig(t, `{"rate_limit": {"mute_for": "1m30s"}}`)
+
+		cfg, err := Load(path)
+
+		expect := Duration(90 * time.Second)
+		if err != nil || cfg.RateLimit.MuteFor != expect {
+			t.Fatalf("Load() = (%v, %v), want (%v, %v)", cfg.RateLimit.MuteFor, err, expect, nil)
+		}
+	})
+
+	t.Run("should fail on numeric durations", func(t *testing.T) {
+		path := writeConfig(t, `{"rate_limit": {"mute_for": 90}}`)
+
+		_, err := Load(path)
+
+		if err == nil {
+			t.Fatalf("Load() = %v, want error", err)
+		}
+	})
+
+	t.Run("should fail on keepalive without timeout", func(t *testing.T) {
+		path := writeConfig(t, `{"keepalive": {"idle": "1m", "timeout": "0s"}}`)
+
+		_, err := Load(path)
+
+		if err == nil {
+			t.Fatalf("Load() = %v, want error", err)
+		}
+	})
+
+	t.Run("should fail on audit rotation without old files", func(t *testing.T) {
+		path := writeConfig(t, `{"audit": {"file": "audit.log", "max_size": 1024, "max_files": 0}}`)
+
+		_, err := Load(path)
+
+		if err == nil || err.Error() != errAuditMaxFiles {
+			t.Fatalf("Load() = %v, want %q", err, errAuditMaxFiles)
+		}
+	})
+
+	t.Run("should fail on unknown settings", func(t *testing.T) {
+		path := writeConfig(t, `{"rate_limits": {}}`)
+
+		_, err := Load(path)
+
+		if err == nil {
+			t.Fatalf("Load() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package connlimit
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Limiter counts open connections in total and per source network, and checks
+// sources against allow and deny lists.
+type Limiter struct {
+	mu        sync.Mutex
+	cfg       config.Limits
+	allow     []*net.IPNet
+	deny      []*net.IPNet
+	total     int
+	perSource map[string]int
+}
+
+func New(cfg config.Limits) (*Limiter, error) {
+	allow, err := ParseCIDRs(cfg.Allow)
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := ParseCIDRs(cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Limiter{
+		cfg:       cfg,
+		allow:     allow,
+		deny:      deny,
+		perSource: make(map[string]int),
+	}, nil
+}
+
+// Acquire reserves a connection slot for the given remote address. On success
+// the returned function must be called once the connection is closed. Addresses
+// without an IP, such as Unix domain sockets, only count towards the total.
+func (l *Limiter) Acquire(addr net.Addr) (func(), error) {
+	ip := AddrIP(addr)
+
+	if ip != nil {
+		if Contains(l.deny, ip) || (len(l.allow) > 0 && !Contains(l.allow, ip)) {
+			return nil, errors.New(errNotAllowed)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.MaxConnections > 0 && l.total >= l.cfg.MaxConnections {
+		return nil, errors.New(errServerFull)
+	}
+
+	source := ""
+	if ip != nil {
+		source = l.source(ip)
+		if l.cfg.MaxPerSource > 0 && l.perSource[source] >= l.cfg.MaxPerSource {
+			return nil, errors.New(errTooManyFromSource)
+		}
+		l.perSource[source]++
+	}
+	l.total++
+
+	released := false
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if released {
+			return
+		}
+		released = true
+
+		l.total--
+		if source != "" {
+			l.perSource[source]--
+			if l.perSource[source] <= 0 {
+				delete(l.perSource, source)
+			}
+		}
+	}, nil
+}
+
+// source returns the network the given IP is counted under.
+func (l *Limiter) source(ip net.IP) string {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(l.cfg.SourcePrefixIPv4, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(l.cfg.SourcePrefixIPv6, 128)).String()
+}
+
+// Contains returns whether the IP is in any of the given networks.
+func Contains(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseCIDRs parses a list of CIDRs. A plain IP is treated as a network of
+// just that address.
+func ParseCIDRs(list []string) ([]*net.IPNet, error) {
+	var result []*net.IPNet
+	for _, s := range list {
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, n)
+	}
+
+	return result, nil
+}
+
+// AddrIP returns the IP of the given address, or nil if it does not have one.
+func AddrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	case *net.IPAddr:
+		return a.IP
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+const (
+	errNotAllowed        = "connections from your address are not allowed"
+	errServerFull        = "server full"
+	errTooManyFromSource = "too many connections from your address"
+)
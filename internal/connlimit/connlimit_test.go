@@ -0,0 +1,93 @@
+package connlimit
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+func tcpAddr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000}
+}
+
+func TestAcquire(t *testing.T) {
+	t.Run("should fail when server is full", func(t *testing.T) {
+		l, _ := New(config.Limits{MaxConnections: 1, SourcePrefixIPv4: 32})
+
+		_, first := l.Acquire(tcpAddr("10.0.0.1"))
+		_, second := l.Acquire(tcpAddr("10.0.0.2"))
+
+		if first != nil || second == nil {
+			t.Fatalf("Acquire() = %v %v, want %v error", first, second, nil)
+		}
+	})
+
+	t.Run("should free slot on release", func(t *testing.T) {
+		l, _ := New(config.Limits{MaxConnections: 1, SourcePrefixIPv4: 32})
+
+		release, _ := l.Acquire(tcpAddr("10.0.0.1"))
+		release()
+		release()
+		_, err := l.Acquire(tcpAddr("10.0.0.2"))
+
+		if err != nil || l.total != 1 {
+			t.Fatalf("Acquire() = %v, want %v; total %v, want 1", err, nil, l.total)
+		}
+	})
+
+	t.Run("should count addresses in the same source network together", func(t *testing.T) {
+		l, _ := New(config.Limits{MaxPerSource: 1, SourcePrefixIPv4: 24})
+
+		_, first := l.Acquire(tcpAddr("10.0.0.1"))
+		_, second := l.Acquire(tcpAddr("10.0.0.2"))
+		_, other := l.Acquire(tcpAddr("10.0.1.1"))
+
+		if first != nil || second == nil || other != nil {
+			t.Fatalf("Acquire() = %v %v %v, want %v error %v", first, second, other, nil, nil)
+		}
+	})
+
+	t.Run("should only allow listed sources", func(t *testing.T) {
+		l, _ := New(config.Limits{Allow: []string{"10.0.0.0/8"}, SourcePrefixIPv4: 32})
+
+		_, allowed := l.Acquire(tcpAddr("10.1.2.3"))
+		_, denied := l.Acquire(tcpAddr("192.168.0.1"))
+
+		if allowed != nil || denied == nil {
+			t.Fatalf("Acquire() = %v %v, want %v error", allowed, denied, nil)
+		}
+	})
+
+	t.Run("should deny listed sources", func(t *testing.T) {
+		l, _ := New(config.Limits{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.0.0.5"}, SourcePrefixIPv4: 32})
+
+		_, err := l.Acquire(tcpAddr("10.0.0.5"))
+
+		if err == nil {
+			t.Fatalf("Acquire() = %v, want error", err)
+		}
+	})
+
+	t.Run("should not limit addresses without an IP per source", func(t *testing.T) {
+		l, _ := New(config.Limits{MaxPerSource: 1, Allow: []string{"10.0.0.0/8"}})
+		addr := &net.UnixAddr{Name: "/tmp/waddle.sock", Net: "unix"}
+
+		_, first := l.Acquire(addr)
+		_, second := l.Acquire(addr)
+
+		if first != nil || second != nil {
+			t.Fatalf("Acquire() = %v %v, want %v %v", first, second, nil, nil)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("should fail on invalid CIDR", func(t *testing.T) {
+		_, err := New(config.Limits{Deny: []string{"10.0.0.0/33"}})
+
+		if err == nil {
+			t.Fatalf("New() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package context
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// account is a registered account as kept in the store. Only a salted hash of
+// the password is kept.
+type account struct {
+	Salt string `json:"salt"`
+	Hash string `json:"hash"`
+}
+
+// Register will create an account named after the user with the password in
+// the message's Args and authenticate the user to it. Registering does not
+// reserve the name; it only lets whoever knows the password get the account's
+// settings back with Identify.
+func (ctx *Context) Register(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	if ctx.opts.Store == nil {
+		return errors.New(errNoAccounts)
+	}
+
+	if _, ok := ctx.opts.Opers[u.Name]; ok || ctx.registered(u.Name) {
+		return errors.New(errAccountExists)
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	acct := account{Salt: hex.EncodeToString(salt), Hash: hashPassword(salt, m.Args[0])}
+	if err := ctx.opts.Store.Save(accountKey(u.Name), acct); err != nil {
+		log.Printf("saving account %q: %v\n", u.Name, err)
+	}
+
+	ctx.setAccount(u, u.Name)
+	ctx.audit(u, "REGISTER", u.Name)
+
+	return nil
+}
+
+// Identify will authenticate the user to the registered account named in the
+// message's Data when the password in its Args matches, which brings back the
+// account's settings such as its ignore list.
+func (ctx *Context) Identify(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	if !ctx.checkAccount(m.Data, m.Args[0]) {
+		ctx.audit(u, "IDENTIFY", m.Data, "failed")
+		return errors.New(errBadAccountLogin)
+	}
+
+	ctx.setAccount(u, m.Data)
+	ctx.audit(u, "IDENTIFY", m.Data)
+
+	return nil
+}
+
+// checkAccount returns whether the account is registered with the password.
+func (ctx *Context) checkAccount(name string, password string) bool {
+	if ctx.opts.Store == nil {
+		return false
+	}
+
+	var acct account
+	ok, err := ctx.opts.Store.Load(accountKey(name), &acct)
+	if err != nil || !ok {
+		return false
+	}
+
+	salt, err := hex.DecodeString(acct.Salt)
+	if err != nil {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(hashPassword(salt, password)), []byte(acct.Hash)) == 1
+}
+
+// registered returns whether there is a registered account with the name.
+func (ctx *Context) registered(name string) bool {
+	if ctx.opts.Store == nil {
+		return false
+	}
+
+	var acct account
+	ok, err := ctx.opts.Store.Load(accountKey(name), &acct)
+	return err == nil && ok
+}
+
+func hashPassword(salt []byte, password string) string {
+	sum := sha256.Sum256(append(append([]byte(nil), salt...), password...))
+	return hex.EncodeToString(sum[:])
+}
+
+func accountKey(name string) string {
+	return "account:" + name
+}
@@ -0,0 +1,106 @@
+package context
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+func TestRegister(t *testing.T) {
+	t.Run("should keep ignore list for the next identify", func(t *testing.T) {
+		store := mapStore{}
+		ctx := NewWithOptions(Options{Store: store})
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Register(alice, &message.Message{Args: []string{"hunter2"}})
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Logout(alice)
+
+		again := wdluser.User{Id: "alice_again", Writer: &mock.MockWriter{}}
+		ctx.Login(&again, &message.Message{Data: "alicia"})
+		identify := ctx.Identify(&again, &message.Message{Data: "alice", Args: []string{"hunter2"}})
+
+		if err != nil || identify != nil || again.Account != "alice" || !ignoring(&again, bob) {
+			t.Fatalf("Register() = %v, Identify() = %v, want %v; account %q, ignores %v", err, identify, nil, again.Account, again.Ignores)
+		}
+	})
+
+	t.Run("should not keep the password", func(t *testing.T) {
+		store := mapStore{}
+		ctx := NewWithOptions(Options{Store: store})
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		ctx.Register(alice, &message.Message{Args: []string{"hunter2"}})
+
+		for key, b := range store {
+			if strings.Contains(string(b), "hunter2") {
+				t.Fatalf("store[%q] = %s, want no password", key, b)
+			}
+		}
+	})
+
+	t.Run("should fail for name already registered", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Store: mapStore{}})
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		ctx.Register(alice, &message.Message{Args: []string{"hunter2"}})
+		ctx.Logout(alice)
+		again := wdluser.User{Id: "alice_again", Writer: &mock.MockWriter{}}
+		ctx.Login(&again, &message.Message{Data: "alice"})
+		err := ctx.Register(&again, &message.Message{Args: []string{"letmein"}})
+
+		if err == nil || err.Error() != errAccountExists {
+			t.Fatalf("Register() = %v, want %q", err, errAccountExists)
+		}
+	})
+
+	t.Run("should fail for operator account name", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Opers: map[string]string{"alice": "hunter2"}, Store: mapStore{}})
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Register(alice, &message.Message{Args: []string{"letmein"}})
+
+		if err == nil || err.Error() != errAccountExists {
+			t.Fatalf("Register() = %v, want %q", err, errAccountExists)
+		}
+	})
+
+	t.Run("should fail without a store", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Register(alice, &message.Message{Args: []string{"hunter2"}})
+
+		if err == nil || err.Error() != errNoAccounts {
+			t.Fatalf("Register() = %v, want %q", err, errNoAccounts)
+		}
+	})
+}
+
+func TestIdentify(t *testing.T) {
+	t.Run("should fail with wrong password", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Store: mapStore{}})
+		alice, _, bob, _ := newRoomWith(&ctx)
+		ctx.Register(alice, &message.Message{Args: []string{"hunter2"}})
+
+		err := ctx.Identify(bob, &message.Message{Data: "alice", Args: []string{"letmein"}})
+
+		if err == nil || err.Error() != errBadAccountLogin || bob.Account != "" {
+			t.Fatalf("Identify() = %v, want %q; account %q", err, errBadAccountLogin, bob.Account)
+		}
+	})
+
+	t.Run("should fail for unknown account", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Store: mapStore{}})
+		_, _, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Identify(bob, &message.Message{Data: "mallory", Args: []string{"hunter2"}})
+
+		if err == nil || err.Error() != errBadAccountLogin {
+			t.Fatalf("Identify() = %v, want %q", err, errBadAccountLogin)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package context
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/ccassise/waddle/internal/audit"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// auditCount is how many entries AUDIT sends when not given a count.
+const auditCount = 20
+
+// Audit will send the user an AUDIT line for each of the latest entries in the
+// audit log, oldest first. The message's Data may hold how many to send.
+// Server operators only.
+func (ctx *Context) Audit(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	count := auditCount
+	if m.Data != "" {
+		n, err := strconv.Atoi(m.Data)
+		if err != nil || n <= 0 {
+			return errors.New(errInvalidCount)
+		}
+		count = n
+	}
+
+	if ctx.opts.Audit == nil {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for _, e := range ctx.opts.Audit.Recent(count) {
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		buf.WriteString("AUDIT ")
+		buf.Write(b)
+		buf.WriteString("\r\n")
+	}
+
+	_, err := u.Writer.Write(buf.Bytes())
+	return err
+}
+
+// audit records an action taken by the user in the audit log.
+func (ctx *Context) audit(u *wdluser.User, action string, args ...string) {
+	if ctx.opts.Audit == nil {
+		return
+	}
+
+	err := ctx.opts.Audit.Record(audit.Entry{
+		Time:    ctx.now(),
+		Action:  action,
+		User:    u.Name,
+		Address: u.Id,
+		Args:    args,
+	})
+	if err != nil {
+		log.Printf("writing audit log: %v\n", err)
+	}
+}
@@ -0,0 +1,90 @@
+package context
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ccassise/waddle/internal/audit"
+	"github.com/ccassise/waddle/internal/config"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// newAuditContext returns a context recording to an in memory audit log, with
+// alice, a server operator, and bob in #room.
+func newAuditContext(t *testing.T) (*Context, *audit.Log, *wdluser.User, *mock.MockWriter, *wdluser.User) {
+	log, err := audit.Open(config.Audit{Recent: 100})
+	if err != nil {
+		t.Fatalf("audit.Open() = %v, want %v", err, nil)
+	}
+
+	ctx := NewWithOptions(Options{Opers: map[string]string{"admin": "hunter2"}, Audit: log})
+	alice, aliceWriter, bob, _ := newRoomWith(&ctx)
+	ctx.Oper(alice, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+
+	return &ctx, log, alice, aliceWriter, bob
+}
+
+// actions returns the actions of the entries separated by spaces.
+func actions(entries []audit.Entry) string {
+	names := make([]string, len(entries))
+	for i := range entries {
+		names[i] = entries[i].Action
+	}
+	return strings.Join(names, " ")
+}
+
+func TestAudit(t *testing.T) {
+	t.Run("should record account and moderation events", func(t *testing.T) {
+		ctx, log, alice, _, bob := newAuditContext(t)
+
+		ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		ctx.Quit(bob, "bye")
+
+		entries := log.Recent(100)
+		expect := "LOGIN LOGIN JOIN JOIN OPER KICK LOGOUT"
+		last := entries[len(entries)-1]
+		if actions(entries) != expect || last.User != "bob" || last.Address != "bob_unique" || last.Args[0] != "bye" {
+			t.Fatalf("audit log = %+v, want %v", entries, expect)
+		}
+	})
+
+	t.Run("should not record parting a chatroom the user is not in", func(t *testing.T) {
+		ctx, log, _, _, bob := newAuditContext(t)
+
+		ctx.Part(bob, &message.Message{Data: "#elsewhere"})
+		ctx.Part(bob, &message.Message{Data: "#room"})
+		ctx.Part(bob, &message.Message{Data: "#room"})
+
+		entries := log.Recent(100)
+		expect := "LOGIN LOGIN JOIN JOIN OPER PART"
+		if actions(entries) != expect {
+			t.Fatalf("audit log = %v, want %v", actions(entries), expect)
+		}
+	})
+
+	t.Run("should send latest entries to server operators", func(t *testing.T) {
+		ctx, _, alice, aliceWriter, _ := newAuditContext(t)
+
+		ctx.Wall(alice, &message.Message{Data: "hello"})
+		aliceWriter.Wrote = nil
+		err := ctx.Audit(alice, &message.Message{Data: "1"})
+
+		expect := `AUDIT {"time":`
+		sent := string(aliceWriter.Wrote)
+		if err != nil || !strings.HasPrefix(sent, expect) || !strings.Contains(sent, `"action":"WALL"`) || strings.Count(sent, "\r\n") != 1 {
+			t.Fatalf("Audit() = %v, want %v; sent %#q, want one WALL entry", err, nil, sent)
+		}
+	})
+
+	t.Run("should fail when not server operator", func(t *testing.T) {
+		ctx, _, _, _, bob := newAuditContext(t)
+
+		err := ctx.Audit(bob, &message.Message{})
+
+		if err == nil || err.Error() != errNotServerOperator {
+			t.Fatalf("Audit() = %v, want %q", err, errNotServerOperator)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/ccassise/waddle/internal/mask"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// Ban will keep users matching the mask in the message's Data out of the
+// chatroom. An optional duration such as "30m" makes the ban lift itself.
+// Banned users already in the chatroom stay but may not send to it. Only
+// operators may do this.
+func (ctx *Context) Ban(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, err := ctx.opRoom(u, m.Receiver)
+	if err != nil {
+		return err
+	}
+
+	mk, err := mask.Parse(m.Data)
+	if err != nil {
+		return err
+	}
+
+	b := ban{mask: mk, setBy: u.Name}
+	if len(m.Args) > 0 {
+		d, err := time.ParseDuration(m.Args[0])
+		if err != nil || d <= 0 {
+			return errors.New(errInvalidDuration)
+		}
+		b.expires = ctx.now().Add(d)
+	}
+
+	ctx.audit(u, "BAN", append([]string{r.name, mk.String()}, m.Args...)...)
+
+	for i := range r.bans {
+		if r.bans[i].mask.String() == mk.String() {
+			r.bans[i] = b
+			return nil
+		}
+	}
+	r.bans = append(r.bans, b)
+
+	return nil
+}
+
+// Unban will lift the ban on the mask in the message's Data. Only operators
+// may do this.
+func (ctx *Context) Unban(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, err := ctx.opRoom(u, m.Receiver)
+	if err != nil {
+		return err
+	}
+
+	for i := range r.bans {
+		if r.bans[i].mask.String() == m.Data {
+			r.bans = append(r.bans[:i], r.bans[i+1:]...)
+			ctx.audit(u, "UNBAN", r.name, m.Data)
+			return nil
+		}
+	}
+
+	return errors.New(errNoSuchBan)
+}
+
+// Banlist will send the user a BANLIST line for each ban of the chatroom in
+// the message's Data.
+func (ctx *Context) Banlist(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	r, ok := ctx.chatroom[m.Data]
+	if !ok || !r.isMember(u) {
+		return errors.New(errUserNotInRoom)
+	}
+
+	r.expireBans(ctx.now())
+
+	var buf bytes.Buffer
+	for _, b := range r.bans {
+		buf.WriteString("BANLIST ")
+		buf.WriteString(r.name)
+		buf.WriteString(" ")
+		buf.WriteString(b.mask.String())
+		buf.WriteString(" ")
+		buf.WriteString(b.setBy)
+		buf.WriteString(" ")
+		if b.expires.IsZero() {
+			buf.WriteString("never")
+		} else {
+			buf.WriteString(b.expires.UTC().Format(time.RFC3339))
+		}
+		buf.WriteString("\r\n")
+	}
+
+	_, err := u.Writer.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,152 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+func TestBan(t *testing.T) {
+	t.Run("should stop matching users from joining", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "b*"})
+		ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		err := ctx.Join(bob, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errBanned {
+			t.Fatalf("Join() = %v, want %q", err, errBanned)
+		}
+	})
+
+	t.Run("should match address", func(t *testing.T) {
+		ctx := New()
+		aliceWriter := mock.MockWriter{}
+		alice := wdluser.User{Id: "192.0.2.1:5000", Writer: &aliceWriter}
+		bob := wdluser.User{Id: "198.51.100.7:5000"}
+
+		ctx.Login(&alice, &message.Message{Data: "alice"})
+		ctx.Login(&bob, &message.Message{Data: "bob"})
+		ctx.Join(&alice, &message.Message{Data: "#room"})
+		ctx.Ban(&alice, &message.Message{Receiver: "#room", Data: "*@198.51.100.0/24"})
+		err := ctx.Join(&bob, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errBanned {
+			t.Fatalf("Join() = %v, want %q", err, errBanned)
+		}
+	})
+
+	t.Run("should stop banned members from sending", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, bobWriter := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello, room!"})
+
+		if err == nil || string(bobWriter.Wrote) != "" {
+			t.Fatalf("Broadcast() = %v, want error; sent %#q, want %#q", err, bobWriter.Wrote, "")
+		}
+	})
+
+	t.Run("should lift itself once expired", func(t *testing.T) {
+		ctx := New()
+		now := time.Unix(1000, 0)
+		ctx.now = func() time.Time { return now }
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "bob", Args: []string{"10m"}})
+		ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		banned := ctx.Join(bob, &message.Message{Data: "#room"})
+		now = now.Add(10 * time.Minute)
+		err := ctx.Join(bob, &message.Message{Data: "#room"})
+
+		if banned == nil || err != nil {
+			t.Fatalf("Join() = %v %v, want error %v", banned, err, nil)
+		}
+	})
+
+	t.Run("should keep chatroom with bans when empty", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		ctx.Part(bob, &message.Message{Data: "#room"})
+		ctx.Part(alice, &message.Message{Data: "#room"})
+		err := ctx.Join(bob, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errBanned {
+			t.Fatalf("Join() = %v, want %q", err, errBanned)
+		}
+	})
+
+	t.Run("should fail when not operator", func(t *testing.T) {
+		ctx := New()
+		_, _, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Ban(bob, &message.Message{Receiver: "#room", Data: "alice"})
+
+		if err == nil || err.Error() != errNotOperator {
+			t.Fatalf("Ban() = %v, want %q", err, errNotOperator)
+		}
+	})
+
+	t.Run("should fail on invalid duration", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "bob", Args: []string{"soon"}})
+
+		if err == nil {
+			t.Fatalf("Ban() = %v, want error", err)
+		}
+	})
+}
+
+func TestUnban(t *testing.T) {
+	t.Run("should let user join again", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		unban := ctx.Unban(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		err := ctx.Join(bob, &message.Message{Data: "#room"})
+
+		if unban != nil || err != nil {
+			t.Fatalf("Unban() = %v, Join() = %v, want %v %v", unban, err, nil, nil)
+		}
+	})
+
+	t.Run("should fail when no such ban", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Unban(alice, &message.Message{Receiver: "#room", Data: "bob"})
+
+		if err == nil {
+			t.Fatalf("Unban() = %v, want error", err)
+		}
+	})
+}
+
+func TestBanlist(t *testing.T) {
+	t.Run("should list bans", func(t *testing.T) {
+		ctx := New()
+		ctx.now = func() time.Time { return time.Unix(0, 0) }
+		alice, _, bob, bobWriter := newRoomWith(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "spam*"})
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "*@192.0.2.*", Args: []string{"1h"}})
+		err := ctx.Banlist(bob, &message.Message{Data: "#room"})
+
+		expect := "BANLIST #room spam* alice never\r\n" +
+			"BANLIST #room *@192.0.2.* alice 1970-01-01T01:00:00Z\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Banlist() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// capMultiline lets a user send and receive messages spanning several lines,
+// escaped into one with message.Escape.
+const capMultiline = "multiline"
+
+// capabilities is every capability a user may enable, in the order CAP lists
+// them.
+var capabilities = []string{capMultiline}
+
+// Cap will enable the capability in the message's Data, or disable it when it
+// begins with '-'. Without a capability the user is sent whether each one is
+// enabled instead. Capabilities may be changed before logging in.
+func (ctx *Context) Cap(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if m.Data == "" {
+		var buf bytes.Buffer
+		for _, name := range capabilities {
+			buf.WriteString("CAP ")
+			buf.WriteString(name)
+			if u.Caps[name] {
+				buf.WriteString(" on\r\n")
+			} else {
+				buf.WriteString(" off\r\n")
+			}
+		}
+
+		_, err := u.Writer.Write(buf.Bytes())
+		return err
+	}
+
+	name := strings.TrimPrefix(m.Data, "-")
+	if !knownCap(name) {
+		return errors.New(errUnknownCap)
+	}
+
+	if name != m.Data {
+		delete(u.Caps, name)
+		return nil
+	}
+
+	if u.Caps == nil {
+		u.Caps = make(map[string]bool)
+	}
+	u.Caps[name] = true
+
+	return nil
+}
+
+func knownCap(name string) bool {
+	for _, c := range capabilities {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// delivery is a message formatted for recipients with and without the
+// multiline capability. Recipients without it are sent each line of the text
+// as a message of its own.
+type delivery struct {
+	lines   []byte
+	escaped []byte
+}
+
+// newDelivery formats the text as lines beginning with the prefix, such as
+// "GOTUSERMSG alice ".
+func newDelivery(prefix string, text string) delivery {
+	if !strings.ContainsAny(text, "\\\n") {
+		b := []byte(prefix + text + "\r\n")
+		return delivery{lines: b, escaped: b}
+	}
+
+	var buf bytes.Buffer
+	for _, line := range strings.Split(text, "\n") {
+		buf.WriteString(prefix)
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+
+	return delivery{
+		lines:   buf.Bytes(),
+		escaped: []byte(prefix + message.Escape(text) + "\r\n"),
+	}
+}
+
+// to returns the message as the user should be sent it.
+func (d delivery) to(u *wdluser.User) []byte {
+	if u.Caps[capMultiline] {
+		return d.escaped
+	}
+	return d.lines
+}
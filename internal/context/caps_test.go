@@ -0,0 +1,100 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+)
+
+func TestCap(t *testing.T) {
+	t.Run("should list capabilities", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+		aliceWriter.Wrote = nil
+		err := ctx.Cap(alice, &message.Message{})
+
+		expect := "CAP multiline on\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Cap() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should disable capability", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+		err := ctx.Cap(alice, &message.Message{Data: "-multiline"})
+
+		if err != nil || alice.Caps[capMultiline] {
+			t.Fatalf("Cap() = %v, want %v; enabled %v", err, nil, alice.Caps)
+		}
+	})
+
+	t.Run("should fail for unknown capability", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Cap(alice, &message.Message{Data: "telepathy"})
+
+		if err == nil {
+			t.Fatalf("Cap() = %v, want error", err)
+		}
+	})
+}
+
+func TestMultiline(t *testing.T) {
+	t.Run("should deliver one message to users with the capability", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+		ctx.Cap(bob, &message.Message{Data: "multiline"})
+
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: `func() {\n` + "\t" + `return "\\"\n}`})
+
+		expect := `GOTROOMMSG alice #room func() {\n` + "\t" + `return "\\"\n}` + "\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q %#q, want %#q", err, nil, aliceWriter.Wrote, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should split message for users without the capability", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, bobWriter := newRoomWith(&ctx)
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "bob", Data: `one\ntwo \\n`})
+
+		expect := "GOTUSERMSG alice one\r\nGOTUSERMSG alice two \\n\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should escape backslashes of senders without the capability", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, bobWriter := newRoomWith(&ctx)
+		ctx.Cap(bob, &message.Message{Data: "multiline"})
+
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "bob", Data: `C:\new`})
+
+		expect := `GOTUSERMSG alice C:\\new` + "\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should reject invalid escapes", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, bobWriter := newRoomWith(&ctx)
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "bob", Data: `tab\t`})
+
+		if err == nil || len(bobWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want error; sent %#q", err, bobWriter.Wrote)
+		}
+	})
+}
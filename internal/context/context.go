@@ -5,22 +5,84 @@ import (
 	"errors"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/ccassise/waddle/internal/audit"
+	"github.com/ccassise/waddle/internal/filter"
 	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/spam"
 	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/plugin"
 )
 
 // Context is a structure for shared data.
 type Context struct {
 	mu       sync.Mutex
-	chatroom map[string][]*wdluser.User
+	opts     Options
+	chatroom map[string]*room
 	user     map[string]*wdluser.User
+	glines   []ban
+	mutes    map[string]mute
+	killed   map[string]string
+	now      func() time.Time
+}
+
+// Options are the server settings a Context enforces.
+type Options struct {
+	// MaxUsers is the most users that may be logged in at once. Zero means
+	// unlimited.
+	MaxUsers int
+
+	// Opers maps the name of each server operator account to its password.
+	// A password of the form "sha256:<hex>" is compared by its SHA-256 hash.
+	Opers map[string]string
+
+	// Store keeps the accounts registered with Register and the settings of
+	// every account, such as ignore lists, including those in Opers. A user
+	// who has not authenticated with Identify or Oper loses its settings when
+	// it logs out. May be nil, in which case no accounts can be registered
+	// and nothing outlives the connection.
+	Store Store
+
+	// RejectIgnoredDMs makes direct messages to a user ignoring the sender
+	// fail with an error instead of being dropped silently.
+	RejectIgnoredDMs bool
+
+	// Filter decides what happens to each message before it is delivered.
+	// May be nil.
+	Filter filter.MessageFilter
+
+	// Spam finds users repeating messages. May be nil.
+	Spam *spam.Detector
+
+	// Audit records moderation and account events. May be nil.
+	Audit *audit.Log
+
+	// Plugins adds commands and is told what users do. May be nil.
+	Plugins *plugin.Set
+}
+
+// Store keeps values by key across restarts.
+type Store interface {
+	// Load decodes the value stored under key into v. Returns false if there
+	// is no such key.
+	Load(key string, v interface{}) (bool, error)
+	// Save stores v under key. A nil v removes the key.
+	Save(key string, v interface{}) error
 }
 
 func New() Context {
+	return NewWithOptions(Options{})
+}
+
+func NewWithOptions(opts Options) Context {
 	return Context{
-		chatroom: make(map[string][]*wdluser.User),
+		opts:     opts,
+		chatroom: make(map[string]*room),
 		user:     make(map[string]*wdluser.User),
+		mutes:    make(map[string]mute),
+		killed:   make(map[string]string),
+		now:      time.Now,
 	}
 }
 
@@ -37,9 +99,19 @@ func (ctx *Context) Login(u *wdluser.User, m *message.Message) error {
 		return errors.New(errUsernameInUse)
 	}
 
+	if ctx.opts.MaxUsers > 0 && len(ctx.user) >= ctx.opts.MaxUsers {
+		return errors.New(errServerFull)
+	}
+
+	if ctx.gline(m.Data, u.Host()) != nil {
+		return errors.New(errServerBanned)
+	}
+
 	u.Name = m.Data
 	u.LoggedIn = true
 	ctx.user[u.Name] = u
+	ctx.audit(u, "LOGIN")
+	ctx.emit(plugin.Login, u, "", "")
 
 	return nil
 }
@@ -48,28 +120,93 @@ func (ctx *Context) Logout(u *wdluser.User) error {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
 
+	ctx.logout(u, "")
+
+	return nil
+}
+
+// Quit will tell everyone sharing a chatroom with the user that the user has
+// left for the given reason, and then log the user out.
+func (ctx *Context) Quit(u *wdluser.User, reason string) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	ctx.quit(u, reason)
+
+	return nil
+}
+
+// quit tells everyone sharing a chatroom with the user that the user has left
+// and logs the user out. A killed user quits for the reason it was killed.
+func (ctx *Context) quit(u *wdluser.User, reason string) {
 	if !u.LoggedIn {
-		return nil
+		return
+	}
+
+	if killed, ok := ctx.killed[u.Id]; ok {
+		reason = killed
 	}
 
-	for _, room := range u.Rooms {
-		if users, ok := ctx.chatroom[room]; ok {
-			for i := range users {
-				if users[i].Id == u.Id {
-					ctx.chatroom[room] = append(users[:i], users[i+1:]...)
-				}
+	var buf bytes.Buffer
+	buf.WriteString("QUIT ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(reason)
+	buf.WriteString("\r\n")
+
+	notified := map[string]bool{u.Id: true}
+	for _, name := range u.Rooms {
+		r, ok := ctx.chatroom[name]
+		if !ok {
+			continue
+		}
+
+		for _, member := range r.users {
+			if !notified[member.Id] {
+				notified[member.Id] = true
+				member.Writer.Write(buf.Bytes())
 			}
 		}
 	}
 
+	ctx.logout(u, reason)
+}
+
+// logout removes the user from all chatrooms and frees its name. The reason,
+// if any, is recorded in the audit log.
+func (ctx *Context) logout(u *wdluser.User, reason string) {
+	if !u.LoggedIn {
+		return
+	}
+
+	if reason != "" {
+		ctx.audit(u, "LOGOUT", reason)
+	} else {
+		ctx.audit(u, "LOGOUT")
+	}
+	ctx.emit(plugin.Logout, u, "", reason)
+
+	for len(u.Rooms) > 0 {
+		ctx.part(u, u.Rooms[0])
+	}
+
+	// Invites are by name, so they must not outlive the user they were sent
+	// to or whoever logs in with the name next could use them.
+	for _, r := range ctx.chatroom {
+		delete(r.invites, u.Name)
+	}
+
 	delete(ctx.user, u.Name)
+	delete(ctx.killed, u.Id)
 	u.LoggedIn = false
+	u.Oper = false
+	u.Account = ""
+	u.Ignores = nil
 	u.Rooms = nil
-
-	return nil
 }
 
-// Join will insert given user into given chatroom.
+// Join will insert given user into given chatroom. The user that creates a
+// chatroom becomes its operator.
 func (ctx *Context) Join(u *wdluser.User, m *message.Message) error {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
@@ -78,15 +215,42 @@ func (ctx *Context) Join(u *wdluser.User, m *message.Message) error {
 		return errors.New(errUnautorized)
 	}
 
-	room := m.Data
+	name := m.Data
+
+	r, ok := ctx.chatroom[name]
+	if !ok {
+		r = newRoom(name)
+		ctx.chatroom[name] = r
+	}
+
+	if r.isMember(u) {
+		return nil
+	}
+
+	key := ""
+	if len(m.Args) > 0 {
+		key = m.Args[0]
+	}
+
+	if err := r.admit(u, key, ctx.now()); err != nil {
+		return err
+	}
+	delete(r.invites, u.Name)
+
+	if len(r.users) == 0 {
+		r.ops[u.Id] = true
+	}
 
-	ctx.chatroom[room] = append(ctx.chatroom[room], u)
-	u.Rooms = append(u.Rooms, room)
+	r.users = append(r.users, u)
+	u.Rooms = append(u.Rooms, name)
+	ctx.audit(u, "JOIN", name)
+	ctx.emit(plugin.Join, u, name, "")
 
 	return nil
 }
 
-// Part will remove the user from a given chatroom.
+// Part will remove the user from a given chatroom. Leaving a chatroom the user
+// is not in does nothing.
 func (ctx *Context) Part(u *wdluser.User, m *message.Message) error {
 	ctx.mu.Lock()
 	defer ctx.mu.Unlock()
@@ -95,18 +259,36 @@ func (ctx *Context) Part(u *wdluser.User, m *message.Message) error {
 		return errors.New(errUnautorized)
 	}
 
-	room := m.Data
+	if r, ok := ctx.chatroom[m.Data]; !ok || !r.isMember(u) {
+		return nil
+	}
 
-	if users, ok := ctx.chatroom[room]; ok {
-		for i := range users {
-			if users[i].Id == u.Id {
-				ctx.chatroom[room] = append(users[:i], users[i+1:]...)
-				break
-			}
+	ctx.audit(u, "PART", m.Data)
+	ctx.emit(plugin.Part, u, m.Data, "")
+	ctx.part(u, m.Data)
+
+	return nil
+}
+
+// part removes the user from the chatroom, and removes the chatroom once it
+// is empty.
+func (ctx *Context) part(u *wdluser.User, name string) {
+	for i := range u.Rooms {
+		if u.Rooms[i] == name {
+			u.Rooms = append(u.Rooms[:i], u.Rooms[i+1:]...)
+			break
 		}
 	}
 
-	return nil
+	r, ok := ctx.chatroom[name]
+	if !ok {
+		return
+	}
+
+	r.remove(u)
+	if r.empty(ctx.now()) {
+		delete(ctx.chatroom, name)
+	}
 }
 
 // Broadcast sends the given message from the given user to appropriate users.
@@ -118,63 +300,103 @@ func (ctx *Context) Broadcast(u *wdluser.User, m *message.Message) error {
 		return errors.New(errUnautorized)
 	}
 
+	if u.Caps[capMultiline] {
+		text, err := message.Unescape(m.Data)
+		if err != nil {
+			return err
+		}
+		decoded := *m
+		decoded.Data = text
+		m = &decoded
+	}
+
+	mt, muted := ctx.muted(u)
+	if muted && !mt.shadow {
+		return errors.New(errMuted)
+	}
+
+	if ctx.opts.Filter != nil {
+		res := ctx.opts.Filter.Filter(filter.Message{Sender: u.Name, Receiver: m.Receiver, Text: m.Data})
+		switch res.Action {
+		case filter.Rewrite:
+			rewritten := *m
+			rewritten.Data = res.Text
+			m = &rewritten
+		case filter.Reject:
+			return errors.New(res.Reason)
+		case filter.Drop:
+			return nil
+		}
+	}
+
+	var err error
 	if strings.HasPrefix(m.Receiver, "#") {
-		return ctx.broadcastRoom(u, m)
+		err = ctx.broadcastRoom(u, m, muted)
+	} else {
+		err = ctx.broadcastUser(u, m, muted)
 	}
 
-	return ctx.broadcastUser(u, m)
+	if err == nil && !muted {
+		ctx.emit(plugin.Message, u, m.Receiver, m.Data)
+	}
+
+	return err
 }
 
 // broadcastRoom sends a given message from a given user to all users in a given room.
-func (ctx *Context) broadcastRoom(u *wdluser.User, m *message.Message) error {
-	users, ok := ctx.chatroom[m.Receiver]
-	if !ok {
+// A shadow-banned user's message is only echoed back to the user.
+func (ctx *Context) broadcastRoom(u *wdluser.User, m *message.Message, shadow bool) error {
+	r, ok := ctx.chatroom[m.Receiver]
+	if !ok || !r.isMember(u) {
 		return errors.New(errUserNotInRoom)
 	}
 
-	isInRoom := false
-	for i := range users {
-		if users[i].Id == u.Id {
-			isInRoom = true
-			break
-		}
+	if err := r.canSend(u, ctx.now()); err != nil {
+		return err
 	}
 
-	if !isInRoom {
-		return errors.New(errUserNotInRoom)
-	}
+	d := newDelivery("GOTROOMMSG "+u.Name+" "+m.Receiver+" ", m.Data)
 
-	var buf bytes.Buffer
-	buf.WriteString("GOTROOMMSG ")
-	buf.WriteString(u.Name)
-	buf.WriteString(" ")
-	buf.WriteString(m.Receiver)
-	buf.WriteString(" ")
-	buf.WriteString(m.Data)
-	buf.WriteString("\r\n")
+	if shadow {
+		u.Writer.Write(d.to(u))
+		return nil
+	}
 
-	for i := range users {
-		users[i].Writer.Write(buf.Bytes())
+	if err := ctx.checkSpam(u, m); err != nil {
+		return err
 	}
 
+	r.sendFrom(u, d)
+
 	return nil
 }
 
 // broadcastUser sends a given message from a given user to a specific user.
-func (ctx *Context) broadcastUser(u *wdluser.User, m *message.Message) error {
+// A shadow-banned user's message is dropped.
+func (ctx *Context) broadcastUser(u *wdluser.User, m *message.Message, shadow bool) error {
 	to, ok := ctx.user[m.Receiver]
 	if !ok {
 		return errors.New(errUserNotLoggedIn)
 	}
 
-	var buf bytes.Buffer
-	buf.WriteString("GOTUSERMSG ")
-	buf.WriteString(u.Name)
-	buf.WriteString(" ")
-	buf.WriteString(m.Data)
-	buf.WriteString("\r\n")
+	if shadow {
+		return nil
+	}
+
+	if ignoring(to, u) {
+		if ctx.opts.RejectIgnoredDMs {
+			return errors.New(errIgnored)
+		}
+		return nil
+	}
+
+	if err := ctx.checkSpam(u, m); err != nil {
+		return err
+	}
+
+	d := newDelivery("GOTUSERMSG "+u.Name+" ", m.Data)
 
-	_, err := to.Writer.Write(buf.Bytes())
+	_, err := to.Writer.Write(d.to(to))
 	if err != nil {
 		return errors.New(errSendFailed)
 	}
@@ -183,10 +405,44 @@ func (ctx *Context) broadcastUser(u *wdluser.User, m *message.Message) error {
 }
 
 const (
-	errSendFailed      = "failed to send message"
-	errUnautorized     = "unauthorized"
-	errUserLoggedIn    = "user already logged in"
-	errUserNotInRoom   = "user not in room"
-	errUserNotLoggedIn = "user not logged in"
-	errUsernameInUse   = "username already in use"
+	errAccountExists     = "account already registered"
+	errBadAccountLogin   = "invalid account name or password"
+	errBadKey            = "wrong room key"
+	errBadOperLogin      = "invalid operator name or password"
+	errBanned            = "banned from room"
+	errIgnored           = "user is ignoring you"
+	errInvalidCount      = "invalid count"
+	errInvalidDuration   = "invalid duration"
+	errInvalidLimit      = "invalid limit"
+	errInvalidLine       = "invalid line"
+	errInviteOnly        = "room is invite only"
+	errKillSelf          = "cannot kill yourself"
+	errKillService       = "cannot kill a service"
+	errMissingModeArg    = "missing mode argument"
+	errModerated         = "room is moderated"
+	errMuted             = "muted"
+	errNoAccounts        = "accounts not available"
+	errNoSuchBan         = "no such ban"
+	errNoSuchRoom        = "no such room"
+	errNotIgnored        = "user not ignored"
+	errNotMuted          = "user not muted"
+	errNotOperator       = "not operator"
+	errNotServerOperator = "not server operator"
+	errRoomExists        = "room already exists"
+	errRoomFull          = "room is full"
+	errSendFailed        = "failed to send message"
+	errServerBanned      = "banned from server"
+	errServerFull        = "server full"
+	errSpam              = "message looks like spam"
+	errTargetInRoom      = "user already in room"
+	errTargetNotInRoom   = "no such user in room"
+	errTooManyModeArgs   = "too many mode arguments"
+	errUnautorized       = "unauthorized"
+	errUnknownCap        = "unknown capability"
+	errUnknownCommand    = "unknown command"
+	errUnknownMode       = "unknown mode"
+	errUserLoggedIn      = "user already logged in"
+	errUserNotInRoom     = "user not in room"
+	errUserNotLoggedIn   = "user not logged in"
+	errUsernameInUse     = "username already in use"
 )
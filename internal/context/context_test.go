@@ -1,8 +1,11 @@
 package context
 
 import (
+	"fmt"
+	"io"
 	"testing"
 
+	"github.com/ccassise/waddle/internal/filter"
 	"github.com/ccassise/waddle/internal/message"
 	"github.com/ccassise/waddle/internal/wdluser"
 	"github.com/ccassise/waddle/test/mock"
@@ -50,6 +53,19 @@ func TestLogin(t *testing.T) {
 			t.Fatalf("Login(%v) = %q, want error", users[1], err)
 		}
 	})
+
+	t.Run("should fail when server is full", func(t *testing.T) {
+		ctx := NewWithOptions(Options{MaxUsers: 1})
+		alice := wdluser.User{Id: "alice_unique"}
+		bob := wdluser.User{Id: "bob_unique"}
+
+		ctx.Login(&alice, &message.Message{Data: "alice"})
+		err := ctx.Login(&bob, &message.Message{Data: "bob"})
+
+		if err == nil || bob.LoggedIn {
+			t.Fatalf("Login(%v) = (%v %v), want (error, false)", bob, err, bob.LoggedIn)
+		}
+	})
 }
 
 func TestLogout(t *testing.T) {
@@ -99,6 +115,29 @@ func TestLogout(t *testing.T) {
 	})
 }
 
+func TestQuit(t *testing.T) {
+	t.Run("should tell users in shared chatrooms once", func(t *testing.T) {
+		ctx := New()
+		aliceWriter := mock.MockWriter{Wrote: make([]byte, 0)}
+		bobWriter := mock.MockWriter{Wrote: make([]byte, 0)}
+		alice := wdluser.User{Id: "alice_unique", Writer: &aliceWriter}
+		bob := wdluser.User{Id: "bob_unique", Writer: &bobWriter}
+
+		ctx.Login(&alice, &message.Message{Data: "alice"})
+		ctx.Login(&bob, &message.Message{Data: "bob"})
+		ctx.Join(&alice, &message.Message{Data: "#room"})
+		ctx.Join(&alice, &message.Message{Data: "#test"})
+		ctx.Join(&bob, &message.Message{Data: "#room"})
+		ctx.Join(&bob, &message.Message{Data: "#test"})
+		err := ctx.Quit(&bob, "ping timeout")
+
+		expect := "QUIT bob ping timeout\r\n"
+		if err != nil || bob.LoggedIn || string(aliceWriter.Wrote) != expect || string(bobWriter.Wrote) != "" {
+			t.Fatalf("Quit() = %v, want %v; sent %#q, want %#q", err, nil, string(aliceWriter.Wrote), expect)
+		}
+	})
+}
+
 func TestJoin(t *testing.T) {
 	t.Run("should fail when not logged in", func(t *testing.T) {
 		ctx := New()
@@ -222,6 +261,83 @@ func TestBroadcast(t *testing.T) {
 	})
 }
 
+func TestBroadcastFilter(t *testing.T) {
+	t.Run("should deliver rewritten message", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Filter: filter.NewProfanity([]string{"darn"})})
+		_, aliceWriter, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "darn it"})
+
+		expect := "GOTROOMMSG bob #room **** it\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should fail with reason of rejecting filter", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Filter: filter.MaxLength(3)})
+		_, aliceWriter, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err == nil || err.Error() != "message too long" || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want %q; sent %#q", err, "message too long", aliceWriter.Wrote)
+		}
+	})
+
+	t.Run("should silently drop when filter drops", func(t *testing.T) {
+		drop, _ := filter.NewRegex("spam", "drop", "", "")
+		ctx := NewWithOptions(Options{Filter: drop})
+		_, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "spam spam"})
+
+		if err != nil || len(aliceWriter.Wrote) != 0 || len(bobWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q %#q", err, nil, aliceWriter.Wrote, bobWriter.Wrote)
+		}
+	})
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, members := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("room of %d", members), func(b *testing.B) {
+			ctx := New()
+			users := make([]wdluser.User, members)
+			for i := range users {
+				users[i] = wdluser.User{Id: fmt.Sprintf("user%d_unique", i), Writer: io.Discard}
+				ctx.Login(&users[i], &message.Message{Data: fmt.Sprintf("user%d", i)})
+				ctx.Join(&users[i], &message.Message{Data: "#room"})
+			}
+			m := message.Message{Receiver: "#room", Data: "the quick brown fox jumps over the lazy dog"}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := ctx.Broadcast(&users[i%members], &m); err != nil {
+					b.Fatalf("Broadcast() = %v, want %v", err, nil)
+				}
+			}
+		})
+	}
+
+	b.Run("to user", func(b *testing.B) {
+		ctx := New()
+		alice := wdluser.User{Id: "alice_unique", Writer: io.Discard}
+		bob := wdluser.User{Id: "bob_unique", Writer: io.Discard}
+		ctx.Login(&alice, &message.Message{Data: "alice"})
+		ctx.Login(&bob, &message.Message{Data: "bob"})
+		m := message.Message{Receiver: "bob", Data: "the quick brown fox jumps over the lazy dog"}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := ctx.Broadcast(&alice, &m); err != nil {
+				b.Fatalf("Broadcast() = %v, want %v", err, nil)
+			}
+		}
+	})
+}
+
 func TestPart(t *testing.T) {
 	t.Run("should not receive a chatroom message after parting", func(t *testing.T) {
 		ctx := New()
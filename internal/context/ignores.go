@@ -0,0 +1,188 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// Ignore will stop messages from the user in the message's Receiver reaching
+// the user, in chatrooms and directly. The ignored user is remembered by its
+// account when it has authenticated to one and otherwise by its host, like
+// mutes, so that whoever takes the name next is not ignored in its place. A
+// user that is not logged in can only be ignored by a registered account
+// name.
+func (ctx *Context) Ignore(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	var key string
+	if target, ok := ctx.user[m.Receiver]; ok {
+		key = ignoreKey(target)
+	} else if ctx.registered(m.Receiver) {
+		key = accountIgnoreKey(m.Receiver)
+	} else {
+		return errors.New(errUserNotLoggedIn)
+	}
+
+	if u.Ignores == nil {
+		u.Ignores = make(map[string]string)
+	}
+	u.Ignores[key] = m.Receiver
+	ctx.saveIgnores(u)
+
+	return nil
+}
+
+// Unignore will let messages from the user in the message's Receiver reach the
+// user again. Both what was ignored under the name and whoever has the name
+// now are no longer ignored.
+func (ctx *Context) Unignore(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	found := false
+	for key, name := range u.Ignores {
+		if name == m.Receiver {
+			delete(u.Ignores, key)
+			found = true
+		}
+	}
+	if target, ok := ctx.user[m.Receiver]; ok {
+		for _, key := range []string{ignoreKey(target), "host:" + target.Host()} {
+			if _, ok := u.Ignores[key]; ok {
+				delete(u.Ignores, key)
+				found = true
+			}
+		}
+	}
+
+	if !found {
+		return errors.New(errNotIgnored)
+	}
+
+	ctx.saveIgnores(u)
+
+	return nil
+}
+
+// Ignorelist will send the user an IGNORELIST line for each user it ignores,
+// by the name it was ignored under.
+func (ctx *Context) Ignorelist(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	var buf bytes.Buffer
+	for _, name := range ignoreNames(u) {
+		buf.WriteString("IGNORELIST ")
+		buf.WriteString(name)
+		buf.WriteString("\r\n")
+	}
+
+	_, err := u.Writer.Write(buf.Bytes())
+	return err
+}
+
+// ignoring returns whether the user ignores the sender, by the sender's account
+// or by its host.
+func ignoring(u *wdluser.User, from *wdluser.User) bool {
+	if len(u.Ignores) == 0 {
+		return false
+	}
+	if from.Account != "" {
+		if _, ok := u.Ignores[accountIgnoreKey(from.Account)]; ok {
+			return true
+		}
+	}
+	_, ok := u.Ignores["host:"+from.Host()]
+	return ok
+}
+
+// setAccount marks the user as authenticated to the registered account and
+// adds the account's saved ignore list to the user's.
+func (ctx *Context) setAccount(u *wdluser.User, account string) {
+	u.Account = account
+
+	if ctx.opts.Store == nil {
+		return
+	}
+
+	var saved map[string]string
+	if _, err := ctx.opts.Store.Load(ignoresKey(account), &saved); err != nil {
+		log.Printf("loading ignore list of %q: %v\n", account, err)
+		return
+	}
+
+	if len(saved) == 0 && len(u.Ignores) == 0 {
+		return
+	}
+
+	if u.Ignores == nil {
+		u.Ignores = make(map[string]string)
+	}
+	for key, name := range saved {
+		u.Ignores[key] = name
+	}
+	ctx.saveIgnores(u)
+}
+
+// saveIgnores saves the user's ignore list when the user has a registered
+// account.
+func (ctx *Context) saveIgnores(u *wdluser.User) {
+	if ctx.opts.Store == nil || u.Account == "" {
+		return
+	}
+
+	var err error
+	if len(u.Ignores) > 0 {
+		err = ctx.opts.Store.Save(ignoresKey(u.Account), u.Ignores)
+	} else {
+		err = ctx.opts.Store.Save(ignoresKey(u.Account), nil)
+	}
+	if err != nil {
+		log.Printf("saving ignore list of %q: %v\n", u.Account, err)
+	}
+}
+
+// ignoreNames returns the names the user ignores in order.
+func ignoreNames(u *wdluser.User) []string {
+	names := make([]string, 0, len(u.Ignores))
+	for _, name := range u.Ignores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ignoreKey returns what the user is ignored by: its account if it has
+// authenticated to one, or else its host.
+func ignoreKey(u *wdluser.User) string {
+	if u.Account != "" {
+		return accountIgnoreKey(u.Account)
+	}
+	return "host:" + u.Host()
+}
+
+func accountIgnoreKey(account string) string {
+	return "account:" + account
+}
+
+func ignoresKey(account string) string {
+	return "ignores:" + account
+}
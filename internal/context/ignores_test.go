@@ -0,0 +1,185 @@
+package context
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// mapStore is a Store kept in memory.
+type mapStore map[string][]byte
+
+func (s mapStore) Load(key string, v interface{}) (bool, error) {
+	b, ok := s[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+func (s mapStore) Save(key string, v interface{}) error {
+	if v == nil {
+		delete(s, key)
+		return nil
+	}
+	b, err := json.Marshal(v)
+	s[key] = b
+	return err
+}
+
+func TestIgnore(t *testing.T) {
+	t.Run("should not deliver room messages from ignored user", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+
+		err := ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		expect := "GOTROOMMSG bob #room hello\r\n"
+		if err != nil || len(aliceWriter.Wrote) != 0 || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Ignore() = %v, want %v; sent %#q %#q, want `` %#q", err, nil, aliceWriter.Wrote, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should drop direct messages from ignored user", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err != nil || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q", err, nil, aliceWriter.Wrote)
+		}
+	})
+
+	t.Run("should reject direct messages from ignored user when configured", func(t *testing.T) {
+		ctx := NewWithOptions(Options{RejectIgnoredDMs: true})
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err == nil || err.Error() != errIgnored {
+			t.Fatalf("Broadcast() = %v, want %q", err, errIgnored)
+		}
+	})
+
+	t.Run("should deliver again after unignore", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		unignore := ctx.Unignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		expect := "GOTUSERMSG bob hello\r\n"
+		if unignore != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Unignore() = %v, want %v; sent %#q, want %#q", unignore, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should fail to unignore user not ignored", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Unignore(alice, &message.Message{Receiver: "bob"})
+
+		if err == nil || err.Error() != errNotIgnored {
+			t.Fatalf("Unignore() = %v, want %q", err, errNotIgnored)
+		}
+	})
+
+	t.Run("should list ignored users in order", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		loginCarol(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "carol"})
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		err := ctx.Ignorelist(alice, &message.Message{})
+
+		expect := "IGNORELIST bob\r\nIGNORELIST carol\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Ignorelist() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should keep ignore list of operator account", func(t *testing.T) {
+		store := mapStore{}
+		ctx := NewWithOptions(Options{Opers: map[string]string{"admin": "hunter2"}, Store: store})
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Oper(alice, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Logout(alice)
+
+		again := wdluser.User{Id: "alice_again"}
+		ctx.Login(&again, &message.Message{Data: "alice"})
+		ctx.Oper(&again, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+
+		if !ignoring(&again, bob) {
+			t.Fatalf("Ignores = %v, want bob", again.Ignores)
+		}
+	})
+
+	t.Run("should not ignore whoever takes the name next", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, _ := newRoomWith(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Logout(bob)
+		other := wdluser.User{Id: "bob_other", Writer: &mock.MockWriter{}}
+		ctx.Login(&other, &message.Message{Data: "bob"})
+		err := ctx.Broadcast(&other, &message.Message{Receiver: "alice", Data: "hello"})
+
+		expect := "GOTUSERMSG bob hello\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should forget ignore list of guest on logout", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		ctx.Ignore(alice, &message.Message{Receiver: "bob"})
+		ctx.Logout(alice)
+
+		if len(alice.Ignores) != 0 {
+			t.Fatalf("Ignores = %v, want none", alice.Ignores)
+		}
+	})
+
+	t.Run("should fail to ignore user not logged in", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Ignore(alice, &message.Message{Receiver: "mallory"})
+
+		if err == nil || err.Error() != errUserNotLoggedIn {
+			t.Fatalf("Ignore() = %v, want %q", err, errUserNotLoggedIn)
+		}
+	})
+
+	t.Run("should ignore registered account not logged in", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Store: mapStore{}})
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+		ctx.Register(carol, &message.Message{Args: []string{"hunter2"}})
+		ctx.Logout(carol)
+
+		err := ctx.Ignore(alice, &message.Message{Receiver: "carol"})
+		again := wdluser.User{Id: "carol_again", Writer: &mock.MockWriter{}}
+		ctx.Login(&again, &message.Message{Data: "carol"})
+		ctx.Identify(&again, &message.Message{Data: "carol", Args: []string{"hunter2"}})
+		ctx.Broadcast(&again, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err != nil || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Ignore() = %v, want %v; sent %#q", err, nil, aliceWriter.Wrote)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// inviteTTL is how long an invite can be used for.
+const inviteTTL = 10 * time.Minute
+
+// Invite will send the user in the message's Receiver an INVITED line for the
+// chatroom in the message's Data. The invite lets the user join the chatroom
+// once, even when it is invite only, within inviteTTL. When the invite is from
+// an operator it also gets the user past the chatroom's bans. Only operators
+// may invite to an invite only chatroom.
+func (ctx *Context) Invite(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	r, ok := ctx.chatroom[m.Data]
+	if !ok || !r.isMember(u) {
+		return errors.New(errUserNotInRoom)
+	}
+
+	if r.modes.inviteOnly && !r.isOp(u) {
+		return errors.New(errNotOperator)
+	}
+
+	target, ok := ctx.user[m.Receiver]
+	if !ok {
+		return errors.New(errUserNotLoggedIn)
+	}
+
+	if r.isMember(target) {
+		return errors.New(errTargetInRoom)
+	}
+
+	r.invites[target.Name] = invite{
+		expires:    ctx.now().Add(inviteTTL),
+		bypassBans: r.isOp(u),
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("INVITED ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(r.name)
+	buf.WriteString("\r\n")
+
+	_, err := target.Writer.Write(buf.Bytes())
+	if err != nil {
+		return errors.New(errSendFailed)
+	}
+
+	return nil
+}
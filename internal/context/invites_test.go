@@ -0,0 +1,111 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+)
+
+func TestInvite(t *testing.T) {
+	t.Run("should send INVITED to user", func(t *testing.T) {
+		ctx := New()
+		_, _, bob, _ := newRoomWith(&ctx)
+		_, carolWriter := loginCarol(&ctx)
+
+		err := ctx.Invite(bob, &message.Message{Receiver: "carol", Data: "#room"})
+
+		expect := "INVITED bob #room\r\n"
+		if err != nil || string(carolWriter.Wrote) != expect {
+			t.Fatalf("Invite() = %v, want %v; sent %#q, want %#q", err, nil, carolWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should get user past bans when from operator", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "carol"})
+		ctx.Invite(alice, &message.Message{Receiver: "carol", Data: "#room"})
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if err != nil {
+			t.Fatalf("Join() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should not get user past bans when not from operator", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "carol"})
+		ctx.Invite(bob, &message.Message{Receiver: "carol", Data: "#room"})
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errBanned {
+			t.Fatalf("Join() = %v, want %q", err, errBanned)
+		}
+	})
+
+	t.Run("should only be used once", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+i"})
+		ctx.Invite(alice, &message.Message{Receiver: "carol", Data: "#room"})
+		ctx.Join(carol, &message.Message{Data: "#room"})
+		ctx.Part(carol, &message.Message{Data: "#room"})
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errInviteOnly {
+			t.Fatalf("Join() = %v, want %q", err, errInviteOnly)
+		}
+	})
+
+	t.Run("should not be used by the next user with the name", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "carol"})
+		ctx.Invite(alice, &message.Message{Receiver: "carol", Data: "#room"})
+		ctx.Logout(carol)
+		other, _ := loginCarol(&ctx)
+		err := ctx.Join(other, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errBanned {
+			t.Fatalf("Join() = %v, want %q", err, errBanned)
+		}
+	})
+
+	t.Run("should expire", func(t *testing.T) {
+		ctx := New()
+		now := time.Unix(1000, 0)
+		ctx.now = func() time.Time { return now }
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+i"})
+		ctx.Invite(alice, &message.Message{Receiver: "carol", Data: "#room"})
+		now = now.Add(inviteTTL)
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errInviteOnly {
+			t.Fatalf("Join() = %v, want %q", err, errInviteOnly)
+		}
+	})
+
+	t.Run("should fail when user already in chatroom", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Invite(alice, &message.Message{Receiver: "bob", Data: "#room"})
+
+		if err == nil || err.Error() != errTargetInRoom {
+			t.Fatalf("Invite() = %v, want %q", err, errTargetInRoom)
+		}
+	})
+}
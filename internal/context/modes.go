@@ -0,0 +1,243 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// modeChange is a single change asked for by MODE, such as +k and its key.
+type modeChange struct {
+	add  bool
+	mode byte
+	arg  string
+}
+
+// Mode will change the modes of the chatroom in the message's Receiver. The
+// message's Data holds the changes, such as "+kl-m", and Args holds the
+// arguments of the changes that take one in the same order. Without changes
+// the user is sent the current modes in a MODES line instead. Only operators
+// may change modes.
+func (ctx *Context) Mode(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	r, ok := ctx.chatroom[m.Receiver]
+	if !ok || !r.isMember(u) {
+		return errors.New(errUserNotInRoom)
+	}
+
+	if m.Data == "" {
+		_, err := u.Writer.Write([]byte("MODES " + r.name + " " + r.modes.String() + "\r\n"))
+		return err
+	}
+
+	if !r.isOp(u) {
+		return errors.New(errNotOperator)
+	}
+
+	changes, err := parseModeChanges(m.Data, m.Args)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if c.mode != 'v' {
+			continue
+		}
+
+		target, ok := ctx.user[c.arg]
+		if !ok || !r.isMember(target) {
+			return errors.New(errTargetNotInRoom)
+		}
+	}
+
+	for _, c := range changes {
+		switch c.mode {
+		case 'i':
+			r.modes.inviteOnly = c.add
+		case 'k':
+			r.modes.key = c.arg
+		case 'l':
+			r.modes.limit, _ = strconv.Atoi(c.arg)
+		case 'm':
+			r.modes.moderated = c.add
+		case 's':
+			r.modes.secret = c.add
+		case 'v':
+			target := ctx.user[c.arg]
+			if c.add {
+				r.voiced[target.Id] = true
+			} else {
+				delete(r.voiced, target.Id)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("MODE ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(r.name)
+	buf.WriteString(" ")
+	buf.WriteString(m.Data)
+	for _, arg := range m.Args {
+		buf.WriteString(" ")
+		buf.WriteString(arg)
+	}
+	buf.WriteString("\r\n")
+
+	r.send(buf.Bytes())
+	ctx.audit(u, "MODE", append([]string{r.name, m.Data}, m.Args...)...)
+
+	return nil
+}
+
+// parseModeChanges pairs each mode in changes with its argument, if it takes
+// one. +k, +l, +v and -v take an argument.
+func parseModeChanges(changes string, args []string) ([]modeChange, error) {
+	var result []modeChange
+
+	add := true
+	for i := 0; i < len(changes); i++ {
+		c := modeChange{add: add, mode: changes[i]}
+
+		switch c.mode {
+		case '+', '-':
+			add = c.mode == '+'
+			continue
+		case 'i', 'm', 's':
+		case 'k', 'l', 'v':
+			if !add && c.mode != 'v' {
+				break
+			}
+
+			if len(args) == 0 {
+				return nil, errors.New(errMissingModeArg)
+			}
+			c.arg, args = args[0], args[1:]
+
+			if c.mode == 'l' {
+				n, err := strconv.Atoi(c.arg)
+				if err != nil || n <= 0 {
+					return nil, errors.New(errInvalidLimit)
+				}
+			}
+		default:
+			return nil, errors.New(errUnknownMode)
+		}
+
+		result = append(result, c)
+	}
+
+	if len(args) > 0 {
+		return nil, errors.New(errTooManyModeArgs)
+	}
+
+	return result, nil
+}
+
+// String returns the modes as a MODE command would set them, such as
+// "+ikl secret 10".
+func (md modes) String() string {
+	var flags strings.Builder
+	var args []string
+
+	flags.WriteString("+")
+	if md.inviteOnly {
+		flags.WriteString("i")
+	}
+	if md.key != "" {
+		flags.WriteString("k")
+		args = append(args, md.key)
+	}
+	if md.limit > 0 {
+		flags.WriteString("l")
+		args = append(args, strconv.Itoa(md.limit))
+	}
+	if md.moderated {
+		flags.WriteString("m")
+	}
+	if md.secret {
+		flags.WriteString("s")
+	}
+
+	return strings.Join(append([]string{flags.String()}, args...), " ")
+}
+
+// List will send the user a LIST line with the number of members for each
+// chatroom. Secret chatrooms are only listed to their members.
+func (ctx *Context) List(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	names := make([]string, 0, len(ctx.chatroom))
+	for name, r := range ctx.chatroom {
+		if len(r.users) > 0 && (!r.modes.secret || r.isMember(u)) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		buf.WriteString("LIST ")
+		buf.WriteString(name)
+		buf.WriteString(" ")
+		buf.WriteString(strconv.Itoa(len(ctx.chatroom[name].users)))
+		buf.WriteString("\r\n")
+	}
+
+	_, err := u.Writer.Write(buf.Bytes())
+	return err
+}
+
+// Names will send the user a NAMES line for each member of the chatroom in the
+// message's Data, followed by "op", "voice" or "service" where they apply.
+// Members of secret chatrooms are only listed to other members.
+func (ctx *Context) Names(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	r, ok := ctx.chatroom[m.Data]
+	if !ok || len(r.users) == 0 || (r.modes.secret && !r.isMember(u)) {
+		return errors.New(errNoSuchRoom)
+	}
+
+	var buf bytes.Buffer
+	for _, member := range r.users {
+		buf.WriteString("NAMES ")
+		buf.WriteString(r.name)
+		buf.WriteString(" ")
+		buf.WriteString(member.Name)
+		if r.isOp(member) {
+			buf.WriteString(" op")
+		} else if r.voiced[member.Id] {
+			buf.WriteString(" voice")
+		}
+		if member.Service {
+			buf.WriteString(" service")
+		}
+		buf.WriteString("\r\n")
+	}
+
+	_, err := u.Writer.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,204 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// loginCarol logs in a third user that is not in any chatroom.
+func loginCarol(ctx *Context) (*wdluser.User, *mock.MockWriter) {
+	carolWriter := mock.MockWriter{Wrote: make([]byte, 0)}
+	carol := wdluser.User{Id: "carol_unique", Writer: &carolWriter}
+
+	ctx.Login(&carol, &message.Message{Data: "carol"})
+
+	return &carol, &carolWriter
+}
+
+func TestMode(t *testing.T) {
+	t.Run("should tell the chatroom about changes", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, bobWriter := newRoomWith(&ctx)
+
+		err := ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+kl", Args: []string{"secret", "10"}})
+
+		expect := "MODE alice #room +kl secret 10\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Mode() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should send current modes when no changes", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, bobWriter := newRoomWith(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+ims-i+l", Args: []string{"5"}})
+		bobWriter.Wrote = nil
+		err := ctx.Mode(bob, &message.Message{Receiver: "#room"})
+
+		expect := "MODES #room +lms 5\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Mode() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should fail when not operator", func(t *testing.T) {
+		ctx := New()
+		_, _, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Mode(bob, &message.Message{Receiver: "#room", Data: "+i"})
+
+		if err == nil || err.Error() != errNotOperator {
+			t.Fatalf("Mode() = %v, want %q", err, errNotOperator)
+		}
+	})
+
+	t.Run("should fail on bad changes", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		tests := []message.Message{
+			{Receiver: "#room", Data: "+x"},
+			{Receiver: "#room", Data: "+k"},
+			{Receiver: "#room", Data: "+l", Args: []string{"many"}},
+			{Receiver: "#room", Data: "+i", Args: []string{"extra"}},
+			{Receiver: "#room", Data: "+v", Args: []string{"carol"}},
+		}
+
+		for i := range tests {
+			if err := ctx.Mode(alice, &tests[i]); err == nil {
+				t.Errorf("Mode(%v) = %v, want error", tests[i], err)
+			}
+		}
+	})
+}
+
+func TestModeEnforcement(t *testing.T) {
+	t.Run("should need key to join", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+k", Args: []string{"secret"}})
+		wrong := ctx.Join(carol, &message.Message{Data: "#room", Args: []string{"guess"}})
+		err := ctx.Join(carol, &message.Message{Data: "#room", Args: []string{"secret"}})
+
+		if wrong == nil || err != nil {
+			t.Fatalf("Join() = %v %v, want error %v", wrong, err, nil)
+		}
+	})
+
+	t.Run("should fail when chatroom is full", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+l", Args: []string{"2"}})
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if err == nil || err.Error() != errRoomFull {
+			t.Fatalf("Join() = %v, want %q", err, errRoomFull)
+		}
+	})
+
+	t.Run("should need invite to join invite only chatroom", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+i"})
+		uninvited := ctx.Join(carol, &message.Message{Data: "#room"})
+		invite := ctx.Invite(alice, &message.Message{Receiver: "carol", Data: "#room"})
+		err := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		if uninvited == nil || invite != nil || err != nil {
+			t.Fatalf("Join() = %v %v, Invite() = %v, want error %v %v", uninvited, err, invite, nil, nil)
+		}
+	})
+
+	t.Run("should only let operators invite to invite only chatroom", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+		loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+i"})
+		err := ctx.Invite(bob, &message.Message{Receiver: "carol", Data: "#room"})
+
+		if err == nil || err.Error() != errNotOperator {
+			t.Fatalf("Invite() = %v, want %q", err, errNotOperator)
+		}
+	})
+
+	t.Run("should only let voiced users and operators send when moderated", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+m"})
+		silenced := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+		op := ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "hello"})
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+v", Args: []string{"bob"}})
+		voiced := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		if silenced == nil || op != nil || voiced != nil {
+			t.Fatalf("Broadcast() = %v %v %v, want error %v %v", silenced, op, voiced, nil, nil)
+		}
+	})
+}
+
+func TestList(t *testing.T) {
+	t.Run("should hide secret chatrooms from non-members", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		carol, carolWriter := loginCarol(&ctx)
+
+		ctx.Join(alice, &message.Message{Data: "#hidden"})
+		ctx.Mode(alice, &message.Message{Receiver: "#hidden", Data: "+s"})
+		aliceWriter.Wrote = nil
+		ctx.List(alice, &message.Message{})
+		err := ctx.List(carol, &message.Message{})
+
+		expectAlice := "LIST #hidden 1\r\nLIST #room 2\r\n"
+		expectCarol := "LIST #room 2\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expectAlice || string(carolWriter.Wrote) != expectCarol {
+			t.Fatalf("List() = %v, want %v; sent %#q %#q, want %#q %#q",
+				err, nil, aliceWriter.Wrote, carolWriter.Wrote, expectAlice, expectCarol)
+		}
+	})
+}
+
+func TestNames(t *testing.T) {
+	t.Run("should list members with their status", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		carol, _ := loginCarol(&ctx)
+		svc, _ := ctx.AddService("logger", func(s *Service, m ServiceMessage) {})
+		defer svc.Close()
+
+		ctx.Join(carol, &message.Message{Data: "#room"})
+		svc.Join("#room")
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+v", Args: []string{"carol"}})
+		aliceWriter.Wrote = nil
+		err := ctx.Names(alice, &message.Message{Data: "#room"})
+
+		expect := "NAMES #room alice op\r\nNAMES #room bob\r\nNAMES #room carol voice\r\nNAMES #room logger service\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Names() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should hide secret chatrooms from non-members", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		carol, carolWriter := loginCarol(&ctx)
+
+		ctx.Mode(alice, &message.Message{Receiver: "#room", Data: "+s"})
+		err := ctx.Names(carol, &message.Message{Data: "#room"})
+
+		if err == nil || len(carolWriter.Wrote) != 0 {
+			t.Fatalf("Names() = %v, want error; sent %#q", err, carolWriter.Wrote)
+		}
+	})
+}
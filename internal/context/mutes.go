@@ -0,0 +1,115 @@
+package context
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// mute stops a user's messages from reaching anyone. A shadow mute lets the
+// user believe the messages were delivered. Mutes are kept by the host the
+// user connected from, like G-lines, so that reconnecting under another name
+// does not lift one and whoever takes the name next does not inherit it.
+type mute struct {
+	name    string
+	setBy   string
+	expires time.Time
+	shadow  bool
+}
+
+// expired returns whether the mute has lifted by the given time. A zero expiry
+// never lifts.
+func (mt mute) expired(now time.Time) bool {
+	return !mt.expires.IsZero() && !now.Before(mt.expires)
+}
+
+// Mute will stop the user in the message's Receiver from sending messages to
+// anyone, for the optional duration in its Args. Server operators only.
+func (ctx *Context) Mute(u *wdluser.User, m *message.Message) error {
+	return ctx.setMute(u, m, false)
+}
+
+// Shadowban will quietly stop the user in the message's Receiver from sending
+// messages to anyone, for the optional duration in its Args. The user is still
+// told the messages were sent. Server operators only.
+func (ctx *Context) Shadowban(u *wdluser.User, m *message.Message) error {
+	return ctx.setMute(u, m, true)
+}
+
+func (ctx *Context) setMute(u *wdluser.User, m *message.Message, shadow bool) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	target, ok := ctx.user[m.Receiver]
+	if !ok {
+		return errors.New(errUserNotLoggedIn)
+	}
+
+	mt := mute{name: target.Name, setBy: u.Name, shadow: shadow}
+	if len(m.Args) > 0 {
+		d, err := time.ParseDuration(m.Args[0])
+		if err != nil || d <= 0 {
+			return errors.New(errInvalidDuration)
+		}
+		mt.expires = ctx.now().Add(d)
+	}
+
+	ctx.mutes[target.Host()] = mt
+	ctx.audit(u, message.StringifyCommand(m.Command), append([]string{m.Receiver}, m.Args...)...)
+
+	return nil
+}
+
+// Unmute will lift a mute or shadow-ban on the user in the message's
+// Receiver. Server operators only.
+func (ctx *Context) Unmute(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	host, ok := ctx.muteHost(m.Receiver)
+	if !ok {
+		return errors.New(errNotMuted)
+	}
+
+	delete(ctx.mutes, host)
+	ctx.audit(u, "UNMUTE", m.Receiver)
+
+	return nil
+}
+
+// muteHost returns the host of the mute on the named user, whether the user
+// is still logged in or the mute was set under that name.
+func (ctx *Context) muteHost(name string) (string, bool) {
+	if u, ok := ctx.user[name]; ok {
+		if _, ok := ctx.mutes[u.Host()]; ok {
+			return u.Host(), true
+		}
+	}
+
+	for host, mt := range ctx.mutes {
+		if mt.name == name {
+			return host, true
+		}
+	}
+	return "", false
+}
+
+// muted returns the mute on the user, dropping it once it has expired.
+func (ctx *Context) muted(u *wdluser.User) (mute, bool) {
+	mt, ok := ctx.mutes[u.Host()]
+	if ok && mt.expired(ctx.now()) {
+		delete(ctx.mutes, u.Host())
+		return mute{}, false
+	}
+	return mt, ok
+}
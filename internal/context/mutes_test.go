@@ -0,0 +1,135 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+func TestMute(t *testing.T) {
+	t.Run("should reject messages from muted user", func(t *testing.T) {
+		ctx, alice, aliceWriter, bob, _ := newOperContext()
+
+		err := ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob"})
+		room := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+		direct := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err != nil || room == nil || room.Error() != errMuted || direct == nil || direct.Error() != errMuted || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v %v, want %q; sent %#q", room, direct, errMuted, aliceWriter.Wrote)
+		}
+	})
+
+	t.Run("should lift after duration", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+		now := time.Now()
+		ctx.now = func() time.Time { return now }
+
+		ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob", Args: []string{"1m"}})
+		now = now.Add(time.Minute)
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		if err != nil {
+			t.Fatalf("Broadcast() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should lift after unmute", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob"})
+		unmute := ctx.Unmute(alice, &message.Message{Receiver: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		if unmute != nil || err != nil {
+			t.Fatalf("Unmute() = %v, Broadcast() = %v, want %v %v", unmute, err, nil, nil)
+		}
+	})
+
+	t.Run("should stay after reconnecting under another name", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob"})
+		ctx.Logout(bob)
+		ctx.Login(bob, &message.Message{Data: "robert"})
+		ctx.Join(bob, &message.Message{Data: "#room"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		if err == nil || err.Error() != errMuted {
+			t.Fatalf("Broadcast() = %v, want %q", err, errMuted)
+		}
+	})
+
+	t.Run("should not pass to the next user with the name", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob"})
+		ctx.Logout(bob)
+		other := wdluser.User{Id: "other_unique", Writer: &mock.MockWriter{}}
+		ctx.Login(&other, &message.Message{Data: "bob"})
+		err := ctx.Broadcast(&other, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err != nil {
+			t.Fatalf("Broadcast() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should lift by name after the user logs out", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob"})
+		ctx.Logout(bob)
+		err := ctx.Unmute(alice, &message.Message{Receiver: "bob"})
+
+		if err != nil || len(ctx.mutes) != 0 {
+			t.Fatalf("Unmute() = %v, want %v; mutes %v", err, nil, ctx.mutes)
+		}
+	})
+
+	t.Run("should fail when not server operator", func(t *testing.T) {
+		ctx, _, _, bob, _ := newOperContext()
+
+		err := ctx.Mute(bob, &message.Message{Command: message.Mute, Receiver: "alice"})
+
+		if err == nil || err.Error() != errNotServerOperator {
+			t.Fatalf("Mute() = %v, want %q", err, errNotServerOperator)
+		}
+	})
+
+	t.Run("should fail on invalid duration", func(t *testing.T) {
+		ctx, alice, _, _, _ := newOperContext()
+
+		err := ctx.Mute(alice, &message.Message{Command: message.Mute, Receiver: "bob", Args: []string{"soon"}})
+
+		if err == nil || err.Error() != errInvalidDuration {
+			t.Fatalf("Mute() = %v, want %q", err, errInvalidDuration)
+		}
+	})
+}
+
+func TestShadowban(t *testing.T) {
+	t.Run("should only echo room messages back to sender", func(t *testing.T) {
+		ctx, alice, aliceWriter, bob, bobWriter := newOperContext()
+
+		ctx.Shadowban(alice, &message.Message{Command: message.Shadowban, Receiver: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "hello"})
+
+		expect := "GOTROOMMSG bob #room hello\r\n"
+		if err != nil || string(bobWriter.Wrote) != expect || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q %#q, want %#q ``", err, nil, bobWriter.Wrote, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should silently drop direct messages", func(t *testing.T) {
+		ctx, alice, aliceWriter, bob, _ := newOperContext()
+
+		ctx.Shadowban(alice, &message.Message{Command: message.Shadowban, Receiver: "bob"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "hello"})
+
+		if err != nil || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q", err, nil, aliceWriter.Wrote)
+		}
+	})
+}
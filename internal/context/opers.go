@@ -0,0 +1,331 @@
+package context
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ccassise/waddle/internal/mask"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// Oper will make the user a server operator when the account name in the
+// message's Data and the password in its Args match a configured operator.
+func (ctx *Context) Oper(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	want, ok := ctx.opts.Opers[m.Data]
+	if !ok || len(m.Args) == 0 || !checkPassword(want, m.Args[0]) {
+		ctx.audit(u, "OPER", m.Data, "failed")
+		return errors.New(errBadOperLogin)
+	}
+
+	u.Oper = true
+	ctx.setAccount(u, m.Data)
+	ctx.audit(u, "OPER", m.Data)
+
+	return nil
+}
+
+// checkPassword compares the password with a configured one, which may be of
+// the form "sha256:<hex>".
+func checkPassword(want string, password string) bool {
+	const prefix = "sha256:"
+	if strings.HasPrefix(want, prefix) {
+		sum := sha256.Sum256([]byte(password))
+		wantSum, err := hex.DecodeString(want[len(prefix):])
+		return err == nil && subtle.ConstantTimeCompare(sum[:], wantSum) == 1
+	}
+
+	return subtle.ConstantTimeCompare([]byte(want), []byte(password)) == 1
+}
+
+// Kill will disconnect the user in the message's Receiver, giving the reason in
+// the message's Data. Server operators only.
+func (ctx *Context) Kill(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	target, ok := ctx.user[m.Receiver]
+	if !ok {
+		return errors.New(errUserNotLoggedIn)
+	}
+
+	if target.Service {
+		return errors.New(errKillService)
+	}
+
+	if target == u {
+		return errors.New(errKillSelf)
+	}
+
+	ctx.kill(u, target, m.Data)
+	ctx.audit(u, "KILL", target.Name, m.Data)
+
+	return nil
+}
+
+// kill sends the target a KILLED line and closes its connection. The target is
+// only marked as killed here: its own connection logs it out once it sees the
+// connection close, giving everyone sharing a chatroom with it the reason.
+func (ctx *Context) kill(u *wdluser.User, target *wdluser.User, reason string) {
+	if _, ok := ctx.killed[target.Id]; ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("KILLED ")
+	buf.WriteString(u.Name)
+	if reason != "" {
+		buf.WriteString(" ")
+		buf.WriteString(reason)
+	}
+	buf.WriteString("\r\n")
+	target.Writer.Write(buf.Bytes())
+
+	quitReason := "killed by " + u.Name
+	if reason != "" {
+		quitReason += ": " + reason
+	}
+	ctx.killed[target.Id] = quitReason
+
+	if target.Closer != nil {
+		target.Closer.Close()
+	}
+}
+
+// Gline will ban users matching the mask in the message's Data from the whole
+// server. Args may hold a duration, where "0" never expires, followed by a
+// reason. Users already logged in that match are killed. Server operators
+// only.
+func (ctx *Context) Gline(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	mk, err := mask.Parse(m.Data)
+	if err != nil {
+		return err
+	}
+
+	gl := ban{mask: mk, setBy: u.Name}
+	if len(m.Args) > 0 {
+		d, err := time.ParseDuration(m.Args[0])
+		if err != nil || d < 0 {
+			return errors.New(errInvalidDuration)
+		}
+		if d > 0 {
+			gl.expires = ctx.now().Add(d)
+		}
+	}
+	if len(m.Args) > 1 {
+		gl.reason = m.Args[1]
+	}
+
+	replaced := false
+	for i := range ctx.glines {
+		if ctx.glines[i].mask.String() == mk.String() {
+			ctx.glines[i] = gl
+			replaced = true
+		}
+	}
+	if !replaced {
+		ctx.glines = append(ctx.glines, gl)
+	}
+	ctx.audit(u, "GLINE", append([]string{mk.String()}, m.Args...)...)
+
+	reason := "G-lined"
+	if gl.reason != "" {
+		reason += ": " + gl.reason
+	}
+	for _, target := range ctx.user {
+		if !target.Oper && !target.Service && mk.Match(target.Name, target.Host()) {
+			ctx.kill(u, target, reason)
+		}
+	}
+
+	return nil
+}
+
+// Ungline will lift the server ban on the mask in the message's Data. Server
+// operators only.
+func (ctx *Context) Ungline(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	for i := range ctx.glines {
+		if ctx.glines[i].mask.String() == m.Data {
+			ctx.glines = append(ctx.glines[:i], ctx.glines[i+1:]...)
+			ctx.audit(u, "UNGLINE", m.Data)
+			return nil
+		}
+	}
+
+	return errors.New(errNoSuchBan)
+}
+
+// gline returns the server ban matching the given name and host, or nil if
+// there is none.
+func (ctx *Context) gline(name string, host string) *ban {
+	now := ctx.now()
+
+	active := ctx.glines[:0]
+	for _, gl := range ctx.glines {
+		if !gl.expired(now) {
+			active = append(active, gl)
+		}
+	}
+	ctx.glines = active
+
+	for i := range ctx.glines {
+		if ctx.glines[i].mask.Match(name, host) {
+			return &ctx.glines[i]
+		}
+	}
+	return nil
+}
+
+// Wall will send the text in the message's Data to every logged in user.
+// Server operators only.
+func (ctx *Context) Wall(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("WALL ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(m.Data)
+	buf.WriteString("\r\n")
+
+	for _, to := range ctx.user {
+		to.Writer.Write(buf.Bytes())
+	}
+	ctx.audit(u, "WALL", m.Data)
+
+	return nil
+}
+
+// Close will remove everyone from the chatroom in the message's Data, giving
+// the optional reason in its Args, and forget the chatroom along with its
+// bans and modes. Server operators only.
+func (ctx *Context) Close(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	r, ok := ctx.chatroom[m.Data]
+	if !ok {
+		return errors.New(errNoSuchRoom)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("CLOSED ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(r.name)
+	if len(m.Args) > 0 {
+		buf.WriteString(" ")
+		buf.WriteString(m.Args[0])
+	}
+	buf.WriteString("\r\n")
+
+	r.send(buf.Bytes())
+
+	for len(r.users) > 0 {
+		ctx.part(r.users[0], r.name)
+	}
+	delete(ctx.chatroom, r.name)
+	ctx.audit(u, "CLOSE", append([]string{r.name}, m.Args...)...)
+
+	return nil
+}
+
+// Rename will move the chatroom in the message's Receiver, along with its
+// members, bans and modes, to the name in the message's Data. Server
+// operators only.
+func (ctx *Context) Rename(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	if err := checkOper(u); err != nil {
+		return err
+	}
+
+	r, ok := ctx.chatroom[m.Receiver]
+	if !ok {
+		return errors.New(errNoSuchRoom)
+	}
+
+	if _, ok := ctx.chatroom[m.Data]; ok {
+		return errors.New(errRoomExists)
+	}
+
+	delete(ctx.chatroom, r.name)
+	old := r.name
+	r.name = m.Data
+	ctx.chatroom[r.name] = r
+
+	for _, member := range r.users {
+		for i := range member.Rooms {
+			if member.Rooms[i] == old {
+				member.Rooms[i] = r.name
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RENAMED ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(old)
+	buf.WriteString(" ")
+	buf.WriteString(r.name)
+	buf.WriteString("\r\n")
+
+	r.send(buf.Bytes())
+	ctx.audit(u, "RENAME", old, r.name)
+
+	return nil
+}
+
+// checkOper checks that the user is a logged in server operator.
+func checkOper(u *wdluser.User) error {
+	if !u.LoggedIn {
+		return errors.New(errUnautorized)
+	}
+
+	if !u.Oper {
+		return errors.New(errNotServerOperator)
+	}
+
+	return nil
+}
@@ -0,0 +1,200 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// mockCloser records whether it was closed.
+type mockCloser struct {
+	closed bool
+}
+
+func (c *mockCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+// newOperContext returns a context with an operator account "admin" whose
+// password is "hunter2", along with alice and bob in #room where alice is an
+// operator of the server.
+func newOperContext() (*Context, *wdluser.User, *mock.MockWriter, *wdluser.User, *mock.MockWriter) {
+	ctx := NewWithOptions(Options{
+		Opers: map[string]string{
+			"admin": "sha256:f52fbd32b2b3b86ff88ef6c490628285f482af15ddcb29541f94bcf526a3f6c7",
+		},
+	})
+	alice, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+	ctx.Oper(alice, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+
+	return &ctx, alice, aliceWriter, bob, bobWriter
+}
+
+func TestOper(t *testing.T) {
+	t.Run("should make user server operator", func(t *testing.T) {
+		_, alice, _, _, _ := newOperContext()
+
+		if !alice.Oper {
+			t.Fatalf("Oper = %v, want %v", alice.Oper, true)
+		}
+	})
+
+	t.Run("should fail on wrong password", func(t *testing.T) {
+		ctx, _, _, bob, _ := newOperContext()
+
+		err := ctx.Oper(bob, &message.Message{Data: "admin", Args: []string{"guess"}})
+
+		if err == nil || bob.Oper {
+			t.Fatalf("Oper() = (%v, %v), want (error, false)", err, bob.Oper)
+		}
+	})
+
+	t.Run("should accept plain text passwords", func(t *testing.T) {
+		ctx := NewWithOptions(Options{Opers: map[string]string{"admin": "hunter2"}})
+		alice := wdluser.User{Id: "alice_unique"}
+
+		ctx.Login(&alice, &message.Message{Data: "alice"})
+		err := ctx.Oper(&alice, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+
+		if err != nil || !alice.Oper {
+			t.Fatalf("Oper() = (%v, %v), want (%v, true)", err, alice.Oper, nil)
+		}
+	})
+}
+
+func TestKill(t *testing.T) {
+	t.Run("should disconnect user", func(t *testing.T) {
+		ctx, alice, aliceWriter, bob, bobWriter := newOperContext()
+		closer := mockCloser{}
+		bob.Closer = &closer
+
+		err := ctx.Kill(alice, &message.Message{Receiver: "bob", Data: "spam"})
+		ctx.Quit(bob, "connection closed")
+
+		expectBob := "KILLED alice spam\r\n"
+		expectAlice := "QUIT bob killed by alice: spam\r\n"
+		if err != nil || bob.LoggedIn || !closer.closed ||
+			string(bobWriter.Wrote) != expectBob || string(aliceWriter.Wrote) != expectAlice {
+			t.Fatalf("Kill() = %v, want %v; sent %#q %#q, want %#q %#q",
+				err, nil, bobWriter.Wrote, aliceWriter.Wrote, expectBob, expectAlice)
+		}
+	})
+
+	t.Run("should leave logging out to the user's own connection", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Kill(alice, &message.Message{Receiver: "bob"})
+
+		if !bob.LoggedIn || len(bob.Rooms) != 1 {
+			t.Fatalf("LoggedIn = %v, Rooms = %v, want %v %v", bob.LoggedIn, bob.Rooms, true, []string{"#room"})
+		}
+	})
+
+	t.Run("should fail to kill yourself", func(t *testing.T) {
+		ctx, alice, aliceWriter, _, _ := newOperContext()
+		closer := mockCloser{}
+		alice.Closer = &closer
+
+		err := ctx.Kill(alice, &message.Message{Receiver: "alice"})
+
+		if err == nil || err.Error() != errKillSelf || closer.closed || len(aliceWriter.Wrote) != 0 {
+			t.Fatalf("Kill() = %v, want %q; closed %v, sent %#q", err, errKillSelf, closer.closed, aliceWriter.Wrote)
+		}
+	})
+
+	t.Run("should fail when not server operator", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		err := ctx.Kill(bob, &message.Message{Receiver: "alice"})
+
+		if err == nil || !alice.LoggedIn {
+			t.Fatalf("Kill() = %v, want error", err)
+		}
+	})
+}
+
+func TestGline(t *testing.T) {
+	t.Run("should kill matching users and stop them logging in", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		err := ctx.Gline(alice, &message.Message{Data: "b*", Args: []string{"0", "go away"}})
+		ctx.Quit(bob, "connection closed")
+		login := ctx.Login(bob, &message.Message{Data: "bobby"})
+
+		if err != nil || login == nil || login.Error() != errServerBanned {
+			t.Fatalf("Gline() = %v, Login() = %v, want %v %q", err, login, nil, errServerBanned)
+		}
+	})
+
+	t.Run("should let users log in after ungline", func(t *testing.T) {
+		ctx, alice, _, bob, _ := newOperContext()
+
+		ctx.Gline(alice, &message.Message{Data: "bob"})
+		ctx.Quit(bob, "connection closed")
+		ungline := ctx.Ungline(alice, &message.Message{Data: "bob"})
+		err := ctx.Login(bob, &message.Message{Data: "bob"})
+
+		if ungline != nil || err != nil {
+			t.Fatalf("Ungline() = %v, Login() = %v, want %v %v", ungline, err, nil, nil)
+		}
+	})
+}
+
+func TestWall(t *testing.T) {
+	t.Run("should send to every user", func(t *testing.T) {
+		ctx, alice, aliceWriter, _, bobWriter := newOperContext()
+		_, carolWriter := loginCarol(ctx)
+
+		err := ctx.Wall(alice, &message.Message{Data: "restarting soon"})
+
+		expect := "WALL alice restarting soon\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect || string(bobWriter.Wrote) != expect || string(carolWriter.Wrote) != expect {
+			t.Fatalf("Wall() = %v, want %v; sent %#q, want %#q", err, nil, carolWriter.Wrote, expect)
+		}
+	})
+}
+
+func TestClose(t *testing.T) {
+	t.Run("should remove everyone and forget chatroom", func(t *testing.T) {
+		ctx, alice, _, bob, bobWriter := newOperContext()
+
+		ctx.Ban(alice, &message.Message{Receiver: "#room", Data: "carol"})
+		err := ctx.Close(alice, &message.Message{Data: "#room", Args: []string{"cleanup"}})
+		carol, _ := loginCarol(ctx)
+		join := ctx.Join(carol, &message.Message{Data: "#room"})
+
+		expect := "CLOSED alice #room cleanup\r\n"
+		if err != nil || join != nil || len(bob.Rooms) != 0 || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Close() = %v, Join() = %v, want %v %v; sent %#q, want %#q", err, join, nil, nil, bobWriter.Wrote, expect)
+		}
+	})
+}
+
+func TestRename(t *testing.T) {
+	t.Run("should move members to new name", func(t *testing.T) {
+		ctx, alice, _, bob, bobWriter := newOperContext()
+
+		err := ctx.Rename(alice, &message.Message{Receiver: "#room", Data: "#lobby"})
+		bobWriter.Wrote = nil
+		ctx.Broadcast(alice, &message.Message{Receiver: "#lobby", Data: "hello"})
+
+		expect := "GOTROOMMSG alice #lobby hello\r\n"
+		if err != nil || bob.Rooms[0] != "#lobby" || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Rename() = %v, want %v; sent %#q, want %#q", err, nil, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should fail when new name is taken", func(t *testing.T) {
+		ctx, alice, _, _, _ := newOperContext()
+
+		ctx.Join(alice, &message.Message{Data: "#lobby"})
+		err := ctx.Rename(alice, &message.Message{Receiver: "#room", Data: "#lobby"})
+
+		if err == nil {
+			t.Fatalf("Rename() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// Op will make the user named in the message an operator of the chatroom.
+// Only operators may do this.
+func (ctx *Context) Op(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, target, err := ctx.opTarget(u, m)
+	if err != nil {
+		return err
+	}
+
+	r.ops[target.Id] = true
+	ctx.audit(u, "OP", r.name, target.Name)
+
+	return nil
+}
+
+// Deop will take operator status of the chatroom away from the user named in
+// the message. Only operators may do this.
+func (ctx *Context) Deop(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, target, err := ctx.opTarget(u, m)
+	if err != nil {
+		return err
+	}
+
+	delete(r.ops, target.Id)
+	ctx.audit(u, "DEOP", r.name, target.Name)
+
+	return nil
+}
+
+// Kick will remove the user named in the message from the chatroom and tell
+// everyone in the chatroom, including the kicked user. Only operators may do
+// this.
+func (ctx *Context) Kick(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, target, err := ctx.opTarget(u, m)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("KICKED ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(r.name)
+	buf.WriteString(" ")
+	buf.WriteString(target.Name)
+	if len(m.Args) > 0 {
+		buf.WriteString(" ")
+		buf.WriteString(m.Args[0])
+	}
+	buf.WriteString("\r\n")
+
+	r.send(buf.Bytes())
+	ctx.part(target, r.name)
+	ctx.audit(u, "KICK", append([]string{r.name, target.Name}, m.Args...)...)
+
+	return nil
+}
+
+// opTarget checks that the user is an operator of the chatroom in the
+// message's Receiver, and returns the chatroom along with the member named in
+// the message's Data.
+func (ctx *Context) opTarget(u *wdluser.User, m *message.Message) (*room, *wdluser.User, error) {
+	r, err := ctx.opRoom(u, m.Receiver)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, ok := ctx.user[m.Data]
+	if !ok || !r.isMember(target) {
+		return nil, nil, errors.New(errTargetNotInRoom)
+	}
+
+	return r, target, nil
+}
+
+// opRoom checks that the user is an operator of the named chatroom and returns
+// the chatroom.
+func (ctx *Context) opRoom(u *wdluser.User, name string) (*room, error) {
+	if !u.LoggedIn {
+		return nil, errors.New(errUnautorized)
+	}
+
+	r, ok := ctx.chatroom[name]
+	if !ok || !r.isMember(u) {
+		return nil, errors.New(errUserNotInRoom)
+	}
+
+	if !r.isOp(u) {
+		return nil, errors.New(errNotOperator)
+	}
+
+	return r, nil
+}
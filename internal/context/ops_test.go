@@ -0,0 +1,106 @@
+package context
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// newRoomWith logs in alice and bob, and has them join #room in that order.
+func newRoomWith(ctx *Context) (*wdluser.User, *mock.MockWriter, *wdluser.User, *mock.MockWriter) {
+	aliceWriter := mock.MockWriter{Wrote: make([]byte, 0)}
+	bobWriter := mock.MockWriter{Wrote: make([]byte, 0)}
+	alice := wdluser.User{Id: "alice_unique", Writer: &aliceWriter}
+	bob := wdluser.User{Id: "bob_unique", Writer: &bobWriter}
+
+	ctx.Login(&alice, &message.Message{Data: "alice"})
+	ctx.Login(&bob, &message.Message{Data: "bob"})
+	ctx.Join(&alice, &message.Message{Data: "#room"})
+	ctx.Join(&bob, &message.Message{Data: "#room"})
+
+	return &alice, &aliceWriter, &bob, &bobWriter
+}
+
+func TestKick(t *testing.T) {
+	t.Run("should remove user and tell the chatroom", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+
+		err := ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob", Args: []string{"be nice"}})
+		ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "hello, room!"})
+
+		kicked := "KICKED alice #room bob be nice\r\n"
+		expect := kicked + "GOTROOMMSG alice #room hello, room!\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect || string(bobWriter.Wrote) != kicked || len(bob.Rooms) != 0 {
+			t.Fatalf("Kick() = %v, want %v; alice got %#q, want %#q; bob got %#q, want %#q",
+				err, nil, aliceWriter.Wrote, expect, bobWriter.Wrote, kicked)
+		}
+	})
+
+	t.Run("should fail when not operator", func(t *testing.T) {
+		ctx := New()
+		_, _, bob, _ := newRoomWith(&ctx)
+
+		err := ctx.Kick(bob, &message.Message{Receiver: "#room", Data: "alice"})
+
+		if err == nil || err.Error() != errNotOperator {
+			t.Fatalf("Kick() = %v, want %q", err, errNotOperator)
+		}
+	})
+
+	t.Run("should fail when target not in chatroom", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "carol"})
+
+		if err == nil {
+			t.Fatalf("Kick() = %v, want error", err)
+		}
+	})
+}
+
+func TestOp(t *testing.T) {
+	t.Run("should let new operator kick", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Op(alice, &message.Message{Receiver: "#room", Data: "bob"})
+		err := ctx.Kick(bob, &message.Message{Receiver: "#room", Data: "alice"})
+
+		if err != nil || len(alice.Rooms) != 0 {
+			t.Fatalf("Kick() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should make first user to join a new chatroom operator", func(t *testing.T) {
+		ctx := New()
+		alice, _, bob, _ := newRoomWith(&ctx)
+
+		ctx.Part(alice, &message.Message{Data: "#room"})
+		ctx.Part(bob, &message.Message{Data: "#room"})
+		ctx.Join(bob, &message.Message{Data: "#room"})
+		ctx.Join(alice, &message.Message{Data: "#room"})
+		err := ctx.Kick(bob, &message.Message{Receiver: "#room", Data: "alice"})
+
+		if err != nil {
+			t.Fatalf("Kick() = %v, want %v", err, nil)
+		}
+	})
+}
+
+func TestDeop(t *testing.T) {
+	t.Run("should stop operator from kicking", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		ctx.Deop(alice, &message.Message{Receiver: "#room", Data: "alice"})
+		err := ctx.Kick(alice, &message.Message{Receiver: "#room", Data: "bob"})
+
+		if err == nil || err.Error() != errNotOperator {
+			t.Fatalf("Kick() = %v, want %q", err, errNotOperator)
+		}
+	})
+}
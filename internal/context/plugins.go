@@ -0,0 +1,164 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/plugin"
+)
+
+// Plugin will run the plugin command named in the message's Data with the
+// message's Args, and send the user the lines it answers with.
+func (ctx *Context) Plugin(u *wdluser.User, m *message.Message) error {
+	ctx.mu.Lock()
+
+	if !u.LoggedIn {
+		ctx.mu.Unlock()
+		return errors.New(errUnautorized)
+	}
+
+	var cmd *plugin.Command
+	ok := false
+	if ctx.opts.Plugins != nil {
+		cmd, ok = ctx.opts.Plugins.Command(m.Data)
+	}
+	if !ok {
+		ctx.mu.Unlock()
+		return errors.New(errUnknownCommand)
+	}
+
+	if cmd.Oper && !u.Oper {
+		ctx.mu.Unlock()
+		return errors.New(errNotServerOperator)
+	}
+
+	call := plugin.Call{User: userInfo(u), Args: m.Args}
+	ctx.mu.Unlock()
+
+	// The handler runs unlocked so that it may use the Context as its
+	// plugin.Server.
+	lines, err := cmd.Handler(ctx, &call)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if err := checkLine(line); err != nil {
+			return err
+		}
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	_, err = u.Writer.Write(buf.Bytes())
+	return err
+}
+
+// SendUser sends the line to the named user.
+func (ctx *Context) SendUser(name string, line string) error {
+	if err := checkLine(line); err != nil {
+		return err
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	to, ok := ctx.user[name]
+	if !ok {
+		return errors.New(errUserNotLoggedIn)
+	}
+
+	_, err := to.Writer.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// SendRoom sends the line to every member of the chatroom.
+func (ctx *Context) SendRoom(room string, line string) error {
+	if err := checkLine(line); err != nil {
+		return err
+	}
+
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, ok := ctx.chatroom[room]
+	if !ok {
+		return errors.New(errNoSuchRoom)
+	}
+
+	r.send([]byte(line + "\r\n"))
+
+	return nil
+}
+
+// UserInfo returns the named logged in user.
+func (ctx *Context) UserInfo(name string) (plugin.User, bool) {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	u, ok := ctx.user[name]
+	if !ok {
+		return plugin.User{}, false
+	}
+
+	return userInfo(u), true
+}
+
+// Members returns the names of the users in the chatroom.
+func (ctx *Context) Members(room string) []string {
+	ctx.mu.Lock()
+	defer ctx.mu.Unlock()
+
+	r, ok := ctx.chatroom[room]
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, len(r.users))
+	for i := range r.users {
+		names[i] = r.users[i].Name
+	}
+
+	return names
+}
+
+// emit passes the event to the plugins' event handlers, if there are any. An
+// event about a chatroom must be emitted while the chatroom still exists, so
+// that whether it is secret is known.
+func (ctx *Context) emit(typ plugin.EventType, u *wdluser.User, target string, text string) {
+	if ctx.opts.Plugins == nil {
+		return
+	}
+
+	e := plugin.Event{Type: typ, Time: ctx.now(), User: u.Name, Target: target, Text: text}
+	if r, ok := ctx.chatroom[target]; ok {
+		e.Secret = r.modes.secret
+	}
+	ctx.opts.Plugins.Emit(ctx, e)
+}
+
+func userInfo(u *wdluser.User) plugin.User {
+	return plugin.User{
+		Name:    u.Name,
+		Address: u.Id,
+		Rooms:   append([]string(nil), u.Rooms...),
+		Account: u.Account,
+		Oper:    u.Oper,
+		Service: u.Service,
+	}
+}
+
+// checkLine returns an error if the line would not be sent as exactly one line.
+func checkLine(line string) error {
+	if strings.ContainsAny(line, "\r\n") {
+		return errors.New(errInvalidLine)
+	}
+	return nil
+}
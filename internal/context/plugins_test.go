@@ -0,0 +1,171 @@
+package context
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/plugin"
+)
+
+var contextEvents = make(chan plugin.Event, 100)
+
+func init() {
+	plugin.Register(plugin.Plugin{
+		Name: "context-test",
+		Commands: []plugin.Command{
+			{
+				Name: "WHOAMI",
+				Handler: func(s plugin.Server, c *plugin.Call) ([]string, error) {
+					members := s.Members(c.User.Rooms[0])
+					return []string{"WHOAMI " + c.User.Name + " " + strings.Join(members, ",")}, nil
+				},
+			},
+			{
+				Name: "ANNOUNCE",
+				Oper: true,
+				Args: []plugin.Arg{{Name: "room", Kind: plugin.Room}, {Name: "text", Kind: plugin.Text}},
+				Handler: func(s plugin.Server, c *plugin.Call) ([]string, error) {
+					return nil, s.SendRoom(c.Args[0], "ANNOUNCE "+c.Args[1])
+				},
+			},
+			{
+				Name: "FAIL",
+				Handler: func(s plugin.Server, c *plugin.Call) ([]string, error) {
+					return nil, errors.New("failed")
+				},
+			},
+		},
+	})
+	plugin.Register(plugin.Plugin{
+		Name: "context-events",
+		OnEvent: func(s plugin.Server, e plugin.Event) {
+			contextEvents <- e
+		},
+	})
+}
+
+// newPluginContext returns a context with the named test plugin enabled.
+func newPluginContext(t *testing.T, name string) *Context {
+	set, err := plugin.Load([]string{name})
+	if err != nil {
+		t.Fatalf("Load() = %v, want %v", err, nil)
+	}
+	t.Cleanup(set.Close)
+
+	ctx := NewWithOptions(Options{Plugins: set})
+	return &ctx
+}
+
+func TestPlugin(t *testing.T) {
+	t.Run("should run command and send its answer", func(t *testing.T) {
+		ctx := newPluginContext(t, "context-test")
+		alice, aliceWriter, _, _ := newRoomWith(ctx)
+		aliceWriter.Wrote = nil
+
+		err := ctx.Plugin(alice, &message.Message{Command: message.Plugin, Data: "WHOAMI"})
+
+		expect := "WHOAMI alice alice,bob\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Plugin() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should limit oper commands to server operators", func(t *testing.T) {
+		ctx := newPluginContext(t, "context-test")
+		alice, aliceWriter, bob, bobWriter := newRoomWith(ctx)
+		m := message.Message{Command: message.Plugin, Data: "ANNOUNCE", Args: []string{"#room", "hello"}}
+
+		denied := ctx.Plugin(bob, &m)
+		alice.Oper = true
+		aliceWriter.Wrote = nil
+		bobWriter.Wrote = nil
+		allowed := ctx.Plugin(alice, &m)
+
+		expect := "ANNOUNCE hello\r\n"
+		if denied == nil || allowed != nil || string(aliceWriter.Wrote) != expect || string(bobWriter.Wrote) != expect {
+			t.Fatalf("Plugin() = %v %v, want error %v; sent %#q %#q", denied, allowed, nil, aliceWriter.Wrote, bobWriter.Wrote)
+		}
+	})
+
+	t.Run("should return handler error", func(t *testing.T) {
+		ctx := newPluginContext(t, "context-test")
+		alice, _, _, _ := newRoomWith(ctx)
+
+		err := ctx.Plugin(alice, &message.Message{Command: message.Plugin, Data: "FAIL"})
+
+		if err == nil || err.Error() != "failed" {
+			t.Fatalf("Plugin() = %v, want %q", err, "failed")
+		}
+	})
+
+	t.Run("should fail for command not enabled", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+
+		err := ctx.Plugin(alice, &message.Message{Command: message.Plugin, Data: "WHOAMI"})
+
+		if err == nil {
+			t.Fatalf("Plugin() = %v, want error", err)
+		}
+	})
+
+	t.Run("should emit lifecycle events", func(t *testing.T) {
+		ctx := newPluginContext(t, "context-events")
+		alice, _, bob, _ := newRoomWith(ctx)
+
+		ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "hello"})
+		ctx.Part(bob, &message.Message{Data: "#room"})
+		ctx.Quit(alice, "bye")
+
+		var got []string
+		for len(got) < 7 {
+			select {
+			case e := <-contextEvents:
+				got = append(got, e.User+":"+eventName(e.Type)+":"+e.Target+":"+e.Text)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("timed out waiting for events, got %q", got)
+			}
+		}
+
+		expect := []string{
+			"alice:login::", "bob:login::", "alice:join:#room:", "bob:join:#room:",
+			"alice:message:#room:hello", "bob:part:#room:", "alice:logout::bye",
+		}
+		if strings.Join(got, " ") != strings.Join(expect, " ") {
+			t.Fatalf("events %q, want %q", got, expect)
+		}
+	})
+}
+
+func TestEmit(t *testing.T) {
+	t.Run("should give the time and whether the chatroom is secret", func(t *testing.T) {
+		ctx := newPluginContext(t, "context-events")
+		now := time.Unix(1000, 0)
+		ctx.now = func() time.Time { return now }
+		carol, _ := loginCarol(ctx)
+
+		ctx.Join(carol, &message.Message{Data: "#hideout"})
+		ctx.Mode(carol, &message.Message{Receiver: "#hideout", Data: "+s"})
+		ctx.Part(carol, &message.Message{Data: "#hideout"})
+
+		var last plugin.Event
+		for i := 0; i < 3; i++ {
+			select {
+			case last = <-contextEvents:
+			case <-time.After(2 * time.Second):
+				t.Fatalf("timed out waiting for events")
+			}
+		}
+
+		if last.Type != plugin.Part || !last.Secret || !last.Time.Equal(now) {
+			t.Fatalf("event %+v, want secret part at %v", last, now)
+		}
+	})
+}
+
+func eventName(typ plugin.EventType) string {
+	return [...]string{"", "login", "join", "part", "message", "logout"}[typ]
+}
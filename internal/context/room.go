@@ -0,0 +1,187 @@
+package context
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ccassise/waddle/internal/mask"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// room is a chatroom along with the state of its members.
+type room struct {
+	name  string
+	users []*wdluser.User
+
+	// ops holds the ids of members that are operators of the chatroom.
+	ops map[string]bool
+
+	// voiced holds the ids of members that may send to a moderated chatroom.
+	voiced map[string]bool
+
+	// invites holds the pending invites by the name of the invited user.
+	invites map[string]invite
+
+	bans  []ban
+	modes modes
+}
+
+// modes are the settings of a chatroom. A limit of zero means unlimited and an
+// empty key means none is needed.
+type modes struct {
+	inviteOnly bool
+	key        string
+	limit      int
+	moderated  bool
+	secret     bool
+}
+
+// ban keeps users matching a mask out of a chatroom, or the server, until it
+// expires. A zero expiry never expires.
+type ban struct {
+	mask    mask.Mask
+	setBy   string
+	expires time.Time
+	reason  string
+}
+
+func (b *ban) expired(now time.Time) bool {
+	return !b.expires.IsZero() && !now.Before(b.expires)
+}
+
+// invite lets a user into an invite only chatroom once, until it expires. An
+// invite from an operator also gets the user past the chatroom's bans.
+type invite struct {
+	expires    time.Time
+	bypassBans bool
+}
+
+func newRoom(name string) *room {
+	return &room{
+		name:    name,
+		ops:     make(map[string]bool),
+		voiced:  make(map[string]bool),
+		invites: make(map[string]invite),
+	}
+}
+
+// isMember returns whether the user is in the chatroom.
+func (r *room) isMember(u *wdluser.User) bool {
+	for i := range r.users {
+		if r.users[i].Id == u.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// isOp returns whether the user is an operator of the chatroom.
+func (r *room) isOp(u *wdluser.User) bool {
+	return r.ops[u.Id]
+}
+
+// remove takes the user out of the chatroom along with any status the user had
+// in it.
+func (r *room) remove(u *wdluser.User) {
+	for i := range r.users {
+		if r.users[i].Id == u.Id {
+			r.users = append(r.users[:i], r.users[i+1:]...)
+			break
+		}
+	}
+
+	delete(r.ops, u.Id)
+	delete(r.voiced, u.Id)
+}
+
+// send writes the bytes to every member of the chatroom.
+func (r *room) send(b []byte) {
+	for i := range r.users {
+		r.users[i].Writer.Write(b)
+	}
+}
+
+// sendFrom writes the message to every member of the chatroom that is not
+// ignoring the sender.
+func (r *room) sendFrom(from *wdluser.User, d delivery) {
+	for i := range r.users {
+		if !ignoring(r.users[i], from) {
+			r.users[i].Writer.Write(d.to(r.users[i]))
+		}
+	}
+}
+
+// isBanned returns whether the user matches any ban of the chatroom that has
+// not expired.
+func (r *room) isBanned(u *wdluser.User, now time.Time) bool {
+	r.expireBans(now)
+
+	for i := range r.bans {
+		if r.bans[i].mask.Match(u.Name, u.Host()) {
+			return true
+		}
+	}
+	return false
+}
+
+// expireBans drops bans that have expired.
+func (r *room) expireBans(now time.Time) {
+	active := r.bans[:0]
+	for _, b := range r.bans {
+		if !b.expired(now) {
+			active = append(active, b)
+		}
+	}
+	r.bans = active
+}
+
+// empty returns whether the chatroom has nothing left worth keeping.
+func (r *room) empty(now time.Time) bool {
+	r.expireBans(now)
+	return len(r.users) == 0 && len(r.bans) == 0
+}
+
+// admit checks whether the user may join the chatroom with the given key.
+func (r *room) admit(u *wdluser.User, key string, now time.Time) error {
+	inv, invited := r.invites[u.Name]
+	if invited && !now.Before(inv.expires) {
+		delete(r.invites, u.Name)
+		invited = false
+	}
+
+	if !(invited && inv.bypassBans) && r.isBanned(u, now) {
+		return errors.New(errBanned)
+	}
+
+	if r.modes.inviteOnly && !invited {
+		return errors.New(errInviteOnly)
+	}
+
+	if r.modes.key != "" && key != r.modes.key {
+		return errors.New(errBadKey)
+	}
+
+	if r.modes.limit > 0 && len(r.users) >= r.modes.limit {
+		return errors.New(errRoomFull)
+	}
+
+	return nil
+}
+
+// canSend checks whether the member may send to the chatroom. Operators always
+// may.
+func (r *room) canSend(u *wdluser.User, now time.Time) error {
+	if r.isOp(u) {
+		return nil
+	}
+
+	if r.isBanned(u, now) {
+		return errors.New(errBanned)
+	}
+
+	if r.modes.moderated && !r.voiced[u.Id] {
+		return errors.New(errModerated)
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/plugin"
+)
+
+// ServiceMessage is a message delivered to a service. Room is empty for a
+// direct message. Text may span several lines.
+type ServiceMessage struct {
+	From string
+	Room string
+	Text string
+}
+
+// ServiceHandler is called with each message delivered to a service. Calls are
+// made one at a time and never while the Context is locked, so the handler may
+// use the service to answer.
+type ServiceHandler func(s *Service, m ServiceMessage)
+
+// Service is a user that lives inside the server. It is logged in, joins
+// chatrooms and sends messages like any other user, but receives messages
+// through its handler rather than a connection.
+type Service struct {
+	ctx    *Context
+	user   wdluser.User
+	handle ServiceHandler
+
+	mu     sync.Mutex
+	wake   *sync.Cond
+	queue  []ServiceMessage
+	closed bool
+}
+
+// AddService logs in a service under the given name. Services are not subject
+// to the user limit, G-lines or spam checks and cannot be killed.
+func (ctx *Context) AddService(name string, handle ServiceHandler) (*Service, error) {
+	s := &Service{ctx: ctx, handle: handle}
+	s.wake = sync.NewCond(&s.mu)
+	s.user = wdluser.User{
+		Id:      "service/" + name,
+		Name:    name,
+		Writer:  serviceWriter{s},
+		Service: true,
+		Caps:    map[string]bool{capMultiline: true},
+	}
+
+	ctx.mu.Lock()
+	if _, ok := ctx.user[name]; ok {
+		ctx.mu.Unlock()
+		return nil, errors.New(errUsernameInUse)
+	}
+	s.user.LoggedIn = true
+	ctx.user[name] = &s.user
+	ctx.emit(plugin.Login, &s.user, "", "")
+	ctx.mu.Unlock()
+
+	go s.run()
+
+	return s, nil
+}
+
+// Name returns the name the service is logged in as.
+func (s *Service) Name() string {
+	return s.user.Name
+}
+
+// Join joins the service to the chatroom, creating it if needed.
+func (s *Service) Join(room string) error {
+	return s.ctx.Join(&s.user, &message.Message{Command: message.Join, Data: room})
+}
+
+// Part removes the service from the chatroom.
+func (s *Service) Part(room string) error {
+	return s.ctx.Part(&s.user, &message.Message{Command: message.Part, Data: room})
+}
+
+// Send sends the text to a chatroom or user as if the service had sent MSG. The
+// text may span several lines.
+func (s *Service) Send(to string, text string) error {
+	return s.ctx.Broadcast(&s.user, &message.Message{Command: message.Msg, Receiver: to, Data: message.Escape(text)})
+}
+
+// Close logs the service out, telling everyone sharing a chatroom with it, and
+// stops its handler once the messages already delivered are handled.
+func (s *Service) Close() {
+	s.ctx.Quit(&s.user, "service stopped")
+
+	s.mu.Lock()
+	s.closed = true
+	s.wake.Signal()
+	s.mu.Unlock()
+}
+
+// run passes queued messages to the handler until the service is closed.
+func (s *Service) run() {
+	for {
+		s.mu.Lock()
+		for len(s.queue) == 0 && !s.closed {
+			s.wake.Wait()
+		}
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			return
+		}
+		m := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+
+		s.handle(s, m)
+	}
+}
+
+// serviceWriter decodes the lines written to a service and queues the messages
+// among them. Other lines, such as notices, are dropped. Services have the
+// multiline capability, so message texts are unescaped.
+type serviceWriter struct {
+	s *Service
+}
+
+func (w serviceWriter) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(string(bytes.TrimRight(b, "\r\n")), "\r\n") {
+		var m ServiceMessage
+		fields := strings.SplitN(line, " ", 4)
+
+		switch {
+		case fields[0] == "GOTROOMMSG" && len(fields) == 4:
+			m = ServiceMessage{From: fields[1], Room: fields[2], Text: fields[3]}
+		case fields[0] == "GOTUSERMSG" && len(fields) >= 3:
+			m = ServiceMessage{From: fields[1], Text: strings.SplitN(line, " ", 3)[2]}
+		default:
+			continue
+		}
+
+		text, err := message.Unescape(m.Text)
+		if err != nil {
+			continue
+		}
+		m.Text = text
+
+		w.s.mu.Lock()
+		if !w.s.closed {
+			w.s.queue = append(w.s.queue, m)
+			w.s.wake.Signal()
+		}
+		w.s.mu.Unlock()
+	}
+
+	return len(b), nil
+}
@@ -0,0 +1,126 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/message"
+)
+
+// nextServiceMessage returns the next message handled by a service, or fails
+// the test.
+func nextServiceMessage(t *testing.T, received chan ServiceMessage) ServiceMessage {
+	select {
+	case m := <-received:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for service message")
+		return ServiceMessage{}
+	}
+}
+
+func TestService(t *testing.T) {
+	t.Run("should receive chatroom and direct messages", func(t *testing.T) {
+		ctx := New()
+		alice, _, _, _ := newRoomWith(&ctx)
+		received := make(chan ServiceMessage, 10)
+		svc, _ := ctx.AddService("logger", func(s *Service, m ServiceMessage) { received <- m })
+		defer svc.Close()
+
+		err := svc.Join("#room")
+		ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "hello, room!"})
+		ctx.Broadcast(alice, &message.Message{Receiver: "logger", Data: "hello, logger!"})
+		room := nextServiceMessage(t, received)
+		direct := nextServiceMessage(t, received)
+
+		expectRoom := ServiceMessage{From: "alice", Room: "#room", Text: "hello, room!"}
+		expectDirect := ServiceMessage{From: "alice", Text: "hello, logger!"}
+		if err != nil || room != expectRoom || direct != expectDirect {
+			t.Fatalf("handled %+v %+v, want %+v %+v", room, direct, expectRoom, expectDirect)
+		}
+	})
+
+	t.Run("should handle messages spanning several lines", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		ctx.Cap(alice, &message.Message{Data: "multiline"})
+		received := make(chan ServiceMessage, 10)
+		done := make(chan struct{}, 10)
+		svc, _ := ctx.AddService("echo", func(s *Service, m ServiceMessage) {
+			received <- m
+			s.Send(m.From, m.Text)
+			done <- struct{}{}
+		})
+		defer svc.Close()
+
+		ctx.Broadcast(alice, &message.Message{Receiver: "echo", Data: `one\ntwo`})
+		m := nextServiceMessage(t, received)
+		<-done
+
+		expect := `GOTUSERMSG echo one\ntwo` + "\r\n"
+		if m.Text != "one\ntwo" || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("handled %q, sent %#q, want %q %#q", m.Text, aliceWriter.Wrote, "one\ntwo", expect)
+		}
+	})
+
+	t.Run("should send messages like a user", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, bob, bobWriter := newRoomWith(&ctx)
+		done := make(chan struct{}, 10)
+		svc, _ := ctx.AddService("echo", func(s *Service, m ServiceMessage) {
+			s.Send(m.From, m.Text)
+			done <- struct{}{}
+		})
+		defer svc.Close()
+
+		ctx.Ignore(bob, &message.Message{Receiver: "echo"})
+		ctx.Broadcast(alice, &message.Message{Receiver: "echo", Data: "ping"})
+		ctx.Broadcast(bob, &message.Message{Receiver: "echo", Data: "ping"})
+		<-done
+		<-done
+
+		expect := "GOTUSERMSG echo ping\r\n"
+		if string(aliceWriter.Wrote) != expect || len(bobWriter.Wrote) != 0 {
+			t.Fatalf("sent %#q %#q, want %#q and nothing", aliceWriter.Wrote, bobWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should fail when name is already in use", func(t *testing.T) {
+		ctx := New()
+		newRoomWith(&ctx)
+
+		_, err := ctx.AddService("alice", func(s *Service, m ServiceMessage) {})
+
+		if err == nil {
+			t.Fatalf("AddService() = %v, want error", err)
+		}
+	})
+
+	t.Run("should not be killed", func(t *testing.T) {
+		ctx, alice, _, _, _ := newOperContext()
+		svc, _ := ctx.AddService("echo", func(s *Service, m ServiceMessage) {})
+		defer svc.Close()
+
+		err := ctx.Kill(alice, &message.Message{Receiver: "echo"})
+		ctx.Gline(alice, &message.Message{Data: "*"})
+
+		if err == nil || !svc.user.LoggedIn {
+			t.Fatalf("Kill() = %v, want error and service logged in", err)
+		}
+	})
+
+	t.Run("should quit when closed", func(t *testing.T) {
+		ctx := New()
+		alice, aliceWriter, _, _ := newRoomWith(&ctx)
+		svc, _ := ctx.AddService("echo", func(s *Service, m ServiceMessage) {})
+		svc.Join("#room")
+
+		svc.Close()
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "echo", Data: "ping"})
+
+		expect := "QUIT echo service stopped\r\n"
+		if err == nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want error; sent %#q, want %#q", err, aliceWriter.Wrote, expect)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package context
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/spam"
+	"github.com/ccassise/waddle/internal/wdluser"
+)
+
+// checkSpam runs the message through the spam detector and carries out its
+// response. It is only given messages that would otherwise be delivered, so
+// that ones rejected for other reasons do not count as repeats. Returns an
+// error when the message must not be delivered.
+func (ctx *Context) checkSpam(u *wdluser.User, m *message.Message) error {
+	if ctx.opts.Spam == nil || u.Oper || u.Service {
+		return nil
+	}
+
+	switch ctx.opts.Spam.Check(u.Name, m.Data) {
+	case spam.Reject:
+		return errors.New(errSpam)
+	case spam.Mute:
+		mt := mute{name: u.Name, setBy: "server"}
+		if d := ctx.opts.Spam.MuteFor(); d > 0 {
+			mt.expires = ctx.now().Add(d)
+		}
+		ctx.mutes[u.Host()] = mt
+		ctx.notifyOpers(u, m)
+		ctx.audit(u, "AUTOMUTE", m.Receiver, m.Data)
+		return errors.New(errMuted)
+	case spam.Notify:
+		ctx.notifyOpers(u, m)
+	}
+
+	return nil
+}
+
+// notifyOpers sends every server operator a SPAM line about the message.
+func (ctx *Context) notifyOpers(u *wdluser.User, m *message.Message) {
+	var buf bytes.Buffer
+	buf.WriteString("SPAM ")
+	buf.WriteString(u.Name)
+	buf.WriteString(" ")
+	buf.WriteString(m.Receiver)
+	buf.WriteString(" ")
+	buf.WriteString(m.Data)
+	buf.WriteString("\r\n")
+
+	for _, to := range ctx.user {
+		if to.Oper {
+			to.Writer.Write(buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package context
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/spam"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/test/mock"
+)
+
+// newSpamContext returns a context whose spam detector gives the given
+// response on the second repeat of a message, along with alice, a server
+// operator, and bob in #room.
+func newSpamContext(t *testing.T, action string) (*Context, *wdluser.User, *mock.MockWriter, *wdluser.User) {
+	d, err := spam.New(config.Spam{
+		Enabled:     true,
+		Window:      config.Duration(time.Minute),
+		UserRepeats: 2,
+		Action:      action,
+		MuteFor:     config.Duration(time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("spam.New() = %v, want %v", err, nil)
+	}
+
+	ctx := NewWithOptions(Options{Opers: map[string]string{"admin": "hunter2"}, Spam: d})
+	alice, aliceWriter, bob, _ := newRoomWith(&ctx)
+	ctx.Oper(alice, &message.Message{Data: "admin", Args: []string{"hunter2"}})
+
+	return &ctx, alice, aliceWriter, bob
+}
+
+func TestSpam(t *testing.T) {
+	t.Run("should reject repeated message", func(t *testing.T) {
+		ctx, _, _, bob := newSpamContext(t, "reject")
+
+		first := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+		second := ctx.Broadcast(bob, &message.Message{Receiver: "alice", Data: "visit my website"})
+
+		if first != nil || second == nil || second.Error() != errSpam {
+			t.Fatalf("Broadcast() = %v %v, want %v %q", first, second, nil, errSpam)
+		}
+	})
+
+	t.Run("should not count messages that are not delivered", func(t *testing.T) {
+		ctx, _, _, bob := newSpamContext(t, "reject")
+
+		ctx.Broadcast(bob, &message.Message{Receiver: "#elsewhere", Data: "visit my website"})
+		ctx.Broadcast(bob, &message.Message{Receiver: "nobody", Data: "visit my website"})
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+
+		if err != nil {
+			t.Fatalf("Broadcast() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should mute sender and tell operators", func(t *testing.T) {
+		ctx, _, aliceWriter, bob := newSpamContext(t, "mute")
+
+		ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+		aliceWriter.Wrote = nil
+		second := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+		third := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "something else entirely"})
+
+		expect := "SPAM bob #room visit my website\r\n"
+		if second == nil || second.Error() != errMuted || third == nil || third.Error() != errMuted || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v %v, want %q; sent %#q, want %#q", second, third, errMuted, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should deliver and tell operators when notifying", func(t *testing.T) {
+		ctx, _, aliceWriter, bob := newSpamContext(t, "notify")
+
+		ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+		aliceWriter.Wrote = nil
+		err := ctx.Broadcast(bob, &message.Message{Receiver: "#room", Data: "visit my website"})
+
+		expect := "SPAM bob #room visit my website\r\nGOTROOMMSG bob #room visit my website\r\n"
+		if err != nil || string(aliceWriter.Wrote) != expect {
+			t.Fatalf("Broadcast() = %v, want %v; sent %#q, want %#q", err, nil, aliceWriter.Wrote, expect)
+		}
+	})
+
+	t.Run("should not check server operators", func(t *testing.T) {
+		ctx, alice, _, _ := newSpamContext(t, "reject")
+
+		ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "server restarting soon"})
+		err := ctx.Broadcast(alice, &message.Message{Receiver: "#room", Data: "server restarting soon"})
+
+		if err != nil {
+			t.Fatalf("Broadcast() = %v, want %v", err, nil)
+		}
+	})
+
+	t.Run("should not check services", func(t *testing.T) {
+		ctx, _, _, _ := newSpamContext(t, "mute")
+		svc, _ := ctx.AddService("echo", func(s *Service, m ServiceMessage) {})
+		defer svc.Close()
+		svc.Join("#room")
+
+		svc.Send("#room", "visit my website")
+		err := svc.Send("#room", "visit my website")
+
+		if _, muted := ctx.mutes[svc.user.Host()]; err != nil || muted {
+			t.Fatalf("Send() = %v, want %v; muted %v, want %v", err, nil, muted, false)
+		}
+	})
+}
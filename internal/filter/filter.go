@@ -0,0 +1,248 @@
+package filter
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Action is what a filter decides to do with a message.
+type Action int
+
+const (
+	// Pass lets the message through unchanged.
+	Pass Action = iota
+	// Rewrite lets the message through with the text in Result.Text.
+	Rewrite
+	// Reject stops the message and tells the sender Result.Reason.
+	Reject
+	// Drop stops the message without telling the sender.
+	Drop
+)
+
+// Message is a message about to be delivered. Receiver is a chatroom or a
+// username.
+type Message struct {
+	Sender   string
+	Receiver string
+	Text     string
+}
+
+// Result is the decision of a filter.
+type Result struct {
+	Action Action
+	Text   string
+	Reason string
+}
+
+// MessageFilter decides what happens to a message before it is delivered.
+type MessageFilter interface {
+	Filter(m Message) Result
+}
+
+// Chain runs each of its filters in turn. A rewrite is seen by the filters
+// after it, and the first reject or drop stops the chain.
+type Chain []MessageFilter
+
+func (c Chain) Filter(m Message) Result {
+	rewritten := false
+	for _, f := range c {
+		res := f.Filter(m)
+		switch res.Action {
+		case Rewrite:
+			m.Text = res.Text
+			rewritten = true
+		case Reject, Drop:
+			return res
+		}
+	}
+
+	if rewritten {
+		return Result{Action: Rewrite, Text: m.Text}
+	}
+	return Result{Action: Pass}
+}
+
+// Pipeline picks the chain of filters for each message.
+type Pipeline struct {
+	def   Chain
+	rooms map[string]Chain
+}
+
+// New builds the pipeline described by the given configuration.
+func New(cfg config.Filters) (*Pipeline, error) {
+	def, err := Build(cfg.Default)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{
+		def:   def,
+		rooms: make(map[string]Chain, len(cfg.Rooms)),
+	}
+	for name, filters := range cfg.Rooms {
+		c, err := Build(filters)
+		if err != nil {
+			return nil, err
+		}
+		p.rooms[name] = c
+	}
+
+	return p, nil
+}
+
+// Filter runs the message through the chain for its receiver.
+func (p *Pipeline) Filter(m Message) Result {
+	if c, ok := p.rooms[m.Receiver]; ok {
+		return c.Filter(m)
+	}
+	return p.def.Filter(m)
+}
+
+// Build returns a chain of the built in filters described by the given
+// configuration.
+func Build(filters []config.Filter) (Chain, error) {
+	c := make(Chain, 0, len(filters))
+	for _, fc := range filters {
+		f, err := build(fc)
+		if err != nil {
+			return nil, err
+		}
+		c = append(c, f)
+	}
+	return c, nil
+}
+
+func build(fc config.Filter) (MessageFilter, error) {
+	switch fc.Type {
+	case "max_length":
+		if fc.Max <= 0 {
+			return nil, errors.New(errMaxLength)
+		}
+		return MaxLength(fc.Max), nil
+	case "profanity":
+		return NewProfanity(fc.Words), nil
+	case "links":
+		return Links{}, nil
+	case "regex":
+		return NewRegex(fc.Pattern, fc.Action, fc.Replace, fc.Reason)
+	default:
+		return nil, errors.New(errUnknownFilter + fc.Type)
+	}
+}
+
+// MaxLength rejects messages longer than the given number of characters.
+type MaxLength int
+
+func (n MaxLength) Filter(m Message) Result {
+	if utf8.RuneCountInString(m.Text) > int(n) {
+		return Result{Action: Reject, Reason: reasonTooLong}
+	}
+	return Result{Action: Pass}
+}
+
+// Profanity masks listed words with asterisks, ignoring case.
+type Profanity struct {
+	re *regexp.Regexp
+}
+
+func NewProfanity(words []string) *Profanity {
+	if len(words) == 0 {
+		return &Profanity{}
+	}
+
+	quoted := make([]string, len(words))
+	for i := range words {
+		quoted[i] = regexp.QuoteMeta(words[i])
+	}
+
+	return &Profanity{
+		re: regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+	}
+}
+
+func (p *Profanity) Filter(m Message) Result {
+	if p.re == nil || !p.re.MatchString(m.Text) {
+		return Result{Action: Pass}
+	}
+
+	text := p.re.ReplaceAllStringFunc(m.Text, func(word string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(word))
+	})
+	return Result{Action: Rewrite, Text: text}
+}
+
+// Links rejects messages containing web links.
+type Links struct{}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)\S`)
+
+func (Links) Filter(m Message) Result {
+	if linkPattern.MatchString(m.Text) {
+		return Result{Action: Reject, Reason: reasonLinks}
+	}
+	return Result{Action: Pass}
+}
+
+// Regex applies an action to messages matching a regular expression.
+type Regex struct {
+	re      *regexp.Regexp
+	action  Action
+	replace string
+	reason  string
+}
+
+// NewRegex returns a filter that rejects, drops or rewrites messages matching
+// pattern. The action is one of "reject", "drop" or "rewrite", and an empty
+// action rejects.
+func NewRegex(pattern string, action string, replace string, reason string) (*Regex, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Regex{re: re, replace: replace, reason: reason}
+	switch action {
+	case "", "reject":
+		r.action = Reject
+		if r.reason == "" {
+			r.reason = reasonRejected
+		}
+	case "drop":
+		r.action = Drop
+	case "rewrite":
+		r.action = Rewrite
+	default:
+		return nil, errors.New(errUnknownAction + action)
+	}
+
+	return r, nil
+}
+
+func (r *Regex) Filter(m Message) Result {
+	if !r.re.MatchString(m.Text) {
+		return Result{Action: Pass}
+	}
+
+	switch r.action {
+	case Rewrite:
+		return Result{Action: Rewrite, Text: r.re.ReplaceAllString(m.Text, r.replace)}
+	default:
+		return Result{Action: r.action, Reason: r.reason}
+	}
+}
+
+const (
+	errMaxLength     = "max_length filter needs a max above zero"
+	errUnknownAction = "unknown filter action: "
+	errUnknownFilter = "unknown filter type: "
+)
+
+const (
+	reasonLinks    = "links not allowed"
+	reasonRejected = "message rejected"
+	reasonTooLong  = "message too long"
+)
@@ -0,0 +1,129 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+func TestChain(t *testing.T) {
+	t.Run("should pass when no filter acts", func(t *testing.T) {
+		c := Chain{MaxLength(10)}
+
+		res := c.Filter(Message{Text: "hello"})
+
+		if res.Action != Pass {
+			t.Fatalf("Filter() = %+v, want Pass", res)
+		}
+	})
+
+	t.Run("should give rewritten text to later filters", func(t *testing.T) {
+		c := Chain{NewProfanity([]string{"darn"}), MaxLength(10)}
+
+		res := c.Filter(Message{Text: "oh DARN it"})
+
+		if res.Action != Rewrite || res.Text != "oh **** it" {
+			t.Fatalf("Filter() = %+v, want Rewrite %#q", res, "oh **** it")
+		}
+	})
+
+	t.Run("should stop at first reject", func(t *testing.T) {
+		c := Chain{Links{}, NewProfanity([]string{"darn"})}
+
+		res := c.Filter(Message{Text: "darn see https://example.com"})
+
+		if res.Action != Reject || res.Reason != reasonLinks {
+			t.Fatalf("Filter() = %+v, want Reject %q", res, reasonLinks)
+		}
+	})
+}
+
+func TestMaxLength(t *testing.T) {
+	t.Run("should count characters rather than bytes", func(t *testing.T) {
+		res := MaxLength(5).Filter(Message{Text: "héllo"})
+
+		if res.Action != Pass {
+			t.Fatalf("Filter() = %+v, want Pass", res)
+		}
+	})
+
+	t.Run("should reject long messages", func(t *testing.T) {
+		res := MaxLength(4).Filter(Message{Text: "hello"})
+
+		if res.Action != Reject || res.Reason != reasonTooLong {
+			t.Fatalf("Filter() = %+v, want Reject %q", res, reasonTooLong)
+		}
+	})
+}
+
+func TestProfanity(t *testing.T) {
+	t.Run("should only mask whole words", func(t *testing.T) {
+		res := NewProfanity([]string{"ass"}).Filter(Message{Text: "pass the class"})
+
+		if res.Action != Pass {
+			t.Fatalf("Filter() = %+v, want Pass", res)
+		}
+	})
+}
+
+func TestRegex(t *testing.T) {
+	t.Run("should drop matching messages", func(t *testing.T) {
+		r, err := NewRegex(`(?i)free money`, "drop", "", "")
+		if err != nil {
+			t.Fatalf("NewRegex() = %v, want %v", err, nil)
+		}
+
+		res := r.Filter(Message{Text: "FREE MONEY here"})
+
+		if res.Action != Drop {
+			t.Fatalf("Filter() = %+v, want Drop", res)
+		}
+	})
+
+	t.Run("should rewrite matches", func(t *testing.T) {
+		r, _ := NewRegex(`\d{4}-\d{4}`, "rewrite", "[redacted]", "")
+
+		res := r.Filter(Message{Text: "call 5555-1234"})
+
+		if res.Action != Rewrite || res.Text != "call [redacted]" {
+			t.Fatalf("Filter() = %+v, want Rewrite %#q", res, "call [redacted]")
+		}
+	})
+
+	t.Run("should fail on unknown action", func(t *testing.T) {
+		_, err := NewRegex(`x`, "explode", "", "")
+
+		if err == nil {
+			t.Fatalf("NewRegex() = %v, want error", err)
+		}
+	})
+}
+
+func TestPipeline(t *testing.T) {
+	t.Run("should use room override in place of default", func(t *testing.T) {
+		p, err := New(config.Filters{
+			Default: []config.Filter{{Type: "links"}},
+			Rooms: map[string][]config.Filter{
+				"#links": {{Type: "max_length", Max: 100}},
+			},
+		})
+		if err != nil {
+			t.Fatalf("New() = %v, want %v", err, nil)
+		}
+
+		room := p.Filter(Message{Receiver: "#links", Text: "https://example.com"})
+		direct := p.Filter(Message{Receiver: "bob", Text: "https://example.com"})
+
+		if room.Action != Pass || direct.Action != Reject {
+			t.Fatalf("Filter() = %+v %+v, want Pass Reject", room, direct)
+		}
+	})
+
+	t.Run("should fail on unknown filter type", func(t *testing.T) {
+		_, err := New(config.Filters{Default: []config.Filter{{Type: "vibes"}}})
+
+		if err == nil {
+			t.Fatalf("New() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,94 @@
+// Package linereader splits a connection's stream into protocol lines.
+package linereader
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Error is a line that breaks the protocol. The line has already been
+// discarded, so the next one can be read.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Reader reads lines ending in a newline. Lines longer than its maximum are
+// discarded up to and including their newline. In strict mode lines must also
+// end in CRLF and may not contain any other CR or LF, or any NUL.
+type Reader struct {
+	r      *bufio.Reader
+	max    int
+	strict bool
+
+	line    []byte
+	discard bool
+}
+
+// New returns a Reader that accepts lines of at most max bytes, counting the
+// line ending. A max of zero means unlimited.
+func New(r io.Reader, max int, strict bool) *Reader {
+	return &Reader{r: bufio.NewReader(r), max: max, strict: strict}
+}
+
+// ReadLine returns the next line including its line ending. The line is only
+// valid until the next call. A partial line read before an error, such as a
+// timeout, is kept and completed by the next call.
+func (lr *Reader) ReadLine() ([]byte, error) {
+	for {
+		chunk, err := lr.r.ReadSlice('\n')
+		if !lr.discard {
+			lr.line = append(lr.line, chunk...)
+			if lr.max > 0 && len(lr.line) > lr.max {
+				lr.line = lr.line[:0]
+				lr.discard = true
+			}
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		line := lr.line
+		lr.line = lr.line[:0]
+
+		if lr.discard {
+			lr.discard = false
+			return nil, Error(errTooLong)
+		}
+
+		if err := lr.check(line); err != nil {
+			return nil, err
+		}
+
+		return line, nil
+	}
+}
+
+// check returns an error if the complete line breaks the rules of strict mode.
+func (lr *Reader) check(line []byte) error {
+	if !lr.strict {
+		return nil
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return Error(errLineEnding)
+	} else if bytes.IndexByte(line[:len(line)-2], '\r') >= 0 {
+		return Error(errBareCR)
+	} else if bytes.IndexByte(line, 0) >= 0 {
+		return Error(errNUL)
+	}
+
+	return nil
+}
+
+const (
+	errBareCR     = "line contains a bare CR"
+	errLineEnding = "lines must end with CRLF"
+	errNUL        = "line contains a NUL"
+	errTooLong    = "line too long"
+)
@@ -0,0 +1,125 @@
+package linereader
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readAll reads lines until an I/O error, returning each line or the error
+// that replaced it.
+func readAll(lr *Reader) []string {
+	var got []string
+	for {
+		line, err := lr.ReadLine()
+		var lineErr Error
+		if errors.As(err, &lineErr) {
+			got = append(got, "ERROR "+lineErr.Error())
+			continue
+		} else if err != nil {
+			return got
+		}
+		got = append(got, string(line))
+	}
+}
+
+// timeoutReader fails every other read with a timeout.
+type timeoutReader struct {
+	r       io.Reader
+	timeout bool
+}
+
+func (tr *timeoutReader) Read(b []byte) (int, error) {
+	tr.timeout = !tr.timeout
+	if tr.timeout {
+		return 0, errTimeout
+	}
+	return tr.r.Read(b)
+}
+
+var errTimeout = errors.New("timeout")
+
+func TestReadLine(t *testing.T) {
+	t.Run("should split lines", func(t *testing.T) {
+		lr := New(strings.NewReader("LOGIN alice\r\nLOGOUT\nJOIN"), 0, false)
+
+		got := readAll(lr)
+
+		expect := []string{"LOGIN alice\r\n", "LOGOUT\n"}
+		if strings.Join(got, "|") != strings.Join(expect, "|") {
+			t.Fatalf("ReadLine() = %q, want %q", got, expect)
+		}
+	})
+
+	t.Run("should discard the rest of a line too long", func(t *testing.T) {
+		input := "MSG #room " + strings.Repeat("x", 10000) + " LOGOUT\r\nLIST\r\n"
+		lr := New(strings.NewReader(input), 64, false)
+
+		got := readAll(lr)
+
+		expect := []string{"ERROR line too long", "LIST\r\n"}
+		if strings.Join(got, "|") != strings.Join(expect, "|") {
+			t.Fatalf("ReadLine() = %q, want %q", got, expect)
+		}
+	})
+
+	t.Run("should count line ending in length", func(t *testing.T) {
+		lr := New(strings.NewReader("LIST\r\nLOGOUT\r\n"), 6, false)
+
+		got := readAll(lr)
+
+		expect := []string{"LIST\r\n", "ERROR line too long"}
+		if strings.Join(got, "|") != strings.Join(expect, "|") {
+			t.Fatalf("ReadLine() = %q, want %q", got, expect)
+		}
+	})
+
+	t.Run("should enforce CRLF and reject NUL in strict mode", func(t *testing.T) {
+		lr := New(strings.NewReader("LIST\nLIST\rLIST\r\nMSG bob a\x00b\r\nLIST\r\n"), 0, true)
+
+		got := readAll(lr)
+
+		expect := []string{
+			"ERROR lines must end with CRLF",
+			"ERROR line contains a bare CR",
+			"ERROR line contains a NUL",
+			"LIST\r\n",
+		}
+		if strings.Join(got, "|") != strings.Join(expect, "|") {
+			t.Fatalf("ReadLine() = %q, want %q", got, expect)
+		}
+	})
+
+	t.Run("should keep partial line across errors", func(t *testing.T) {
+		lr := New(&timeoutReader{r: &slowReader{s: "LOGIN alice\r\n"}}, 0, true)
+
+		var got []string
+		for i := 0; i < 100 && len(got) == 0; i++ {
+			line, err := lr.ReadLine()
+			if err == nil {
+				got = append(got, string(line))
+			} else if err != errTimeout {
+				t.Fatalf("ReadLine() = %v, want %v", err, errTimeout)
+			}
+		}
+
+		if len(got) != 1 || got[0] != "LOGIN alice\r\n" {
+			t.Fatalf("ReadLine() = %q, want %q", got, "LOGIN alice\r\n")
+		}
+	})
+}
+
+// slowReader returns one byte per read.
+type slowReader struct {
+	s string
+}
+
+func (sr *slowReader) Read(b []byte) (int, error) {
+	if sr.s == "" {
+		return 0, io.EOF
+	}
+	n := copy(b[:1], sr.s)
+	sr.s = sr.s[n:]
+	return n, nil
+}
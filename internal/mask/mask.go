@@ -0,0 +1,102 @@
+package mask
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// Mask matches users by name and, optionally, by address. It is written as
+// <name>[@<address>] where the name and address may use the wildcards '*' and
+// '?', or the address may be a CIDR such as 10.0.0.0/8.
+type Mask struct {
+	Name    string
+	Address string
+	network *net.IPNet
+}
+
+// Parse parses the given mask.
+func Parse(s string) (Mask, error) {
+	m := Mask{Name: s}
+
+	if i := strings.LastIndexByte(s, '@'); i >= 0 {
+		m.Name = s[:i]
+		m.Address = s[i+1:]
+		if m.Address == "" {
+			return Mask{}, errors.New(errInvalidMask)
+		}
+
+		if strings.Contains(m.Address, "/") {
+			_, n, err := net.ParseCIDR(m.Address)
+			if err != nil {
+				return Mask{}, errors.New(errInvalidMask)
+			}
+			m.network = n
+		}
+	}
+
+	if m.Name == "" {
+		return Mask{}, errors.New(errInvalidMask)
+	}
+
+	return m, nil
+}
+
+// Match returns whether the mask matches the given username and address.
+func (m Mask) Match(name string, address string) bool {
+	if !Wildcard(m.Name, name) {
+		return false
+	}
+
+	if m.Address == "" {
+		return true
+	}
+
+	if m.network != nil {
+		ip := net.ParseIP(address)
+		return ip != nil && m.network.Contains(ip)
+	}
+
+	return Wildcard(m.Address, address)
+}
+
+func (m Mask) String() string {
+	if m.Address == "" {
+		return m.Name
+	}
+	return m.Name + "@" + m.Address
+}
+
+// Wildcard returns whether s matches the pattern, where '*' matches any number
+// of characters and '?' matches exactly one.
+func Wildcard(pattern string, s string) bool {
+	// Position to resume from after the last '*', or -1 if there was none.
+	star, resume := -1, 0
+
+	p, i := 0, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, i
+			p++
+		case star >= 0:
+			resume++
+			p, i = star+1, resume
+		default:
+			return false
+		}
+	}
+
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+const (
+	errInvalidMask = "invalid mask"
+)
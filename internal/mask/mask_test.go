@@ -0,0 +1,64 @@
+package mask
+
+import (
+	"testing"
+)
+
+func TestWildcard(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		expect  bool
+	}{
+		{"bob", "bob", true},
+		{"bob", "bobby", false},
+		{"bob*", "bobby", true},
+		{"*by", "bobby", true},
+		{"b?b", "bob", true},
+		{"b?b", "bb", false},
+		{"*o*o*", "foo", true},
+		{"*", "", true},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+
+	for _, test := range tests {
+		if actual := Wildcard(test.pattern, test.s); actual != test.expect {
+			t.Errorf("Wildcard(%q, %q) = %v, want %v", test.pattern, test.s, actual, test.expect)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Run("should match name only", func(t *testing.T) {
+		m, err := Parse("spam*")
+
+		if err != nil || !m.Match("spammer", "192.0.2.1") || m.Match("alice", "192.0.2.1") {
+			t.Fatalf("Parse() = (%v, %v), want name only mask", m, err)
+		}
+	})
+
+	t.Run("should match wildcard address", func(t *testing.T) {
+		m, err := Parse("*@192.0.2.*")
+
+		if err != nil || !m.Match("alice", "192.0.2.1") || m.Match("alice", "198.51.100.1") {
+			t.Fatalf("Parse() = (%v, %v), want address mask", m, err)
+		}
+	})
+
+	t.Run("should match CIDR address", func(t *testing.T) {
+		m, err := Parse("*@2001:db8::/32")
+
+		if err != nil || !m.Match("alice", "2001:db8::1") || m.Match("alice", "192.0.2.1") {
+			t.Fatalf("Parse() = (%v, %v), want CIDR mask", m, err)
+		}
+	})
+
+	t.Run("should fail on invalid mask", func(t *testing.T) {
+		for _, s := range []string{"", "@192.0.2.1", "bob@", "*@10.0.0.0/99"} {
+			if _, err := Parse(s); err == nil {
+				t.Errorf("Parse(%q) = %v, want error", s, err)
+			}
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package message
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 )
 
 // Message represents all of the necessary information in order to carry out a
@@ -10,6 +12,8 @@ type Message struct {
 	Command  int
 	Receiver string
 	Data     string
+	// Args holds any arguments after the ones stored in Receiver and Data.
+	Args []string
 }
 
 // TODO: Add HELP command?
@@ -20,17 +24,68 @@ const (
 	Part
 	Msg
 	Logout
+	Ping
+	Pong
+	Op
+	Deop
+	Kick
+	Ban
+	Unban
+	Banlist
+	Mode
+	Invite
+	List
+	Oper
+	Kill
+	Gline
+	Ungline
+	Wall
+	Close
+	Rename
+	Mute
+	Unmute
+	Shadowban
+	Ignore
+	Unignore
+	Ignorelist
+	Audit
+	Names
+	Cap
+	Register
+	Identify
+	Plugin
 )
 
 // Compares two messages and determines their equality.
 func (t *Message) Equal(rhs *Message) bool {
-	return t.Command == rhs.Command && t.Receiver == rhs.Receiver && t.Data == rhs.Data
+	if t.Command != rhs.Command || t.Receiver != rhs.Receiver || t.Data != rhs.Data || len(t.Args) != len(rhs.Args) {
+		return false
+	}
+
+	for i := range t.Args {
+		if t.Args[i] != rhs.Args[i] {
+			return false
+		}
+	}
+
+	return true
 }
 
 func (t Message) String() string {
 	return fmt.Sprintf("{ %v %#q %#q }", StringifyCommand(t.Command), t.Receiver, t.Data)
 }
 
+// Lookup returns the built in command with the given name, or zero if there is
+// none.
+func Lookup(name string) int {
+	for command := Login; command < Plugin; command++ {
+		if StringifyCommand(command) == name {
+			return command
+		}
+	}
+	return 0
+}
+
 // Returns the string version of a given command. Used for testing/debugging.
 func StringifyCommand(command int) string {
 	switch command {
@@ -44,7 +99,124 @@ func StringifyCommand(command int) string {
 		return "MSG"
 	case Logout:
 		return "LOGOUT"
+	case Ping:
+		return "PING"
+	case Pong:
+		return "PONG"
+	case Op:
+		return "OP"
+	case Deop:
+		return "DEOP"
+	case Kick:
+		return "KICK"
+	case Ban:
+		return "BAN"
+	case Unban:
+		return "UNBAN"
+	case Banlist:
+		return "BANLIST"
+	case Mode:
+		return "MODE"
+	case Invite:
+		return "INVITE"
+	case List:
+		return "LIST"
+	case Oper:
+		return "OPER"
+	case Kill:
+		return "KILL"
+	case Gline:
+		return "GLINE"
+	case Ungline:
+		return "UNGLINE"
+	case Wall:
+		return "WALL"
+	case Close:
+		return "CLOSE"
+	case Rename:
+		return "RENAME"
+	case Mute:
+		return "MUTE"
+	case Unmute:
+		return "UNMUTE"
+	case Shadowban:
+		return "SHADOWBAN"
+	case Ignore:
+		return "IGNORE"
+	case Unignore:
+		return "UNIGNORE"
+	case Ignorelist:
+		return "IGNORELIST"
+	case Audit:
+		return "AUDIT"
+	case Names:
+		return "NAMES"
+	case Cap:
+		return "CAP"
+	case Register:
+		return "REGISTER"
+	case Identify:
+		return "IDENTIFY"
+	case Plugin:
+		return "PLUGIN"
 	default:
 		return ""
 	}
 }
+
+// Escape encodes text that may span several lines as one line of the multiline
+// capability, writing each newline as \n and each backslash as \\.
+func Escape(text string) string {
+	if !strings.ContainsAny(text, "\\\n") {
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+
+	return b.String()
+}
+
+// Unescape decodes a line of the multiline capability written by Escape.
+func Unescape(line string) (string, error) {
+	if strings.IndexByte(line, '\\') < 0 {
+		return line, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] != '\\' {
+			b.WriteByte(line[i])
+			continue
+		}
+
+		i++
+		if i == len(line) {
+			return "", errors.New(errInvalidEscape)
+		}
+
+		switch line[i] {
+		case '\\':
+			b.WriteByte('\\')
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			return "", errors.New(errInvalidEscape)
+		}
+	}
+
+	return b.String(), nil
+}
+
+const (
+	errInvalidEscape = "invalid escape"
+)
@@ -40,4 +40,67 @@ func TestEqual(t *testing.T) {
 			t.Fatalf("%v != %v", lhs, rhs)
 		}
 	})
+
+	t.Run("should not be equal when args differ", func(t *testing.T) {
+		lhs := Message{
+			Command: Login,
+			Data:    "alice",
+			Args:    []string{"secret"},
+		}
+
+		rhs := Message{
+			Command: Login,
+			Data:    "alice",
+			Args:    []string{"hunter2"},
+		}
+
+		if lhs.Equal(&rhs) {
+			t.Fatalf("%v != %v", lhs, rhs)
+		}
+	})
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("should find built in commands", func(t *testing.T) {
+		if Lookup("JOIN") != Join || Lookup("NAMES") != Names {
+			t.Fatalf("Lookup() = %v %v, want %v %v", Lookup("JOIN"), Lookup("NAMES"), Join, Names)
+		}
+	})
+
+	t.Run("should not find other commands", func(t *testing.T) {
+		if Lookup("SEEN") != 0 || Lookup("PLUGIN") != 0 || Lookup("join") != 0 {
+			t.Fatalf("Lookup() = %v %v %v, want 0", Lookup("SEEN"), Lookup("PLUGIN"), Lookup("join"))
+		}
+	})
+}
+
+func TestEscape(t *testing.T) {
+	t.Run("should escape newlines and backslashes", func(t *testing.T) {
+		text := "if x {\n\treturn `C:\\`\n}"
+
+		actual := Escape(text)
+
+		expect := `if x {\n` + "\t" + `return ` + "`" + `C:\\` + "`" + `\n}`
+		if actual != expect {
+			t.Fatalf("Escape(%#q) = %#q, want %#q", text, actual, expect)
+		}
+	})
+
+	t.Run("should undo escaping", func(t *testing.T) {
+		text := "one\n\ntwo \\n three\\"
+
+		actual, err := Unescape(Escape(text))
+
+		if actual != text || err != nil {
+			t.Fatalf("Unescape(Escape(%#q)) = (%#q, %v), want (%#q, %v)", text, actual, err, text, nil)
+		}
+	})
+
+	t.Run("should fail on unknown or unfinished escapes", func(t *testing.T) {
+		for _, line := range []string{`a\tb`, `ends with \`} {
+			if _, err := Unescape(line); err == nil {
+				t.Fatalf("Unescape(%#q) = %v, want error", line, err)
+			}
+		}
+	})
 }
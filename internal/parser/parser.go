@@ -10,314 +10,349 @@ import (
 	"github.com/ccassise/waddle/internal/message"
 )
 
-// Parse will parse the given string and return its representation.
-func Parse(b []byte) (message.Message, error) {
-	p := parser{
-		msg: message.Message{},
-		buf: bytes.NewBuffer(b),
-	}
-
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return message.Message{}, err
-	}
-
-	switch ch {
-	case 'J':
-		p.buf.UnreadByte()
-		err = p.parseOneArgCmd(message.Join, p.parseRoom)
-		if err != nil {
-			return message.Message{}, err
-		}
-	case 'L':
-		p.buf.UnreadByte()
-		err = p.parseL()
-		if err != nil {
-			return message.Message{}, err
-		}
-	case 'M':
-		p.buf.UnreadByte()
-		err = p.parseMessage()
-		if err != nil {
-			return message.Message{}, err
-		}
-	case 'P':
-		p.buf.UnreadByte()
-		err = p.parseOneArgCmd(message.Part, p.parseRoom)
-		if err != nil {
-			return message.Message{}, err
-		}
-	}
-
-	return p.msg, nil
-}
-
-type parser struct {
-	msg message.Message
-	buf *bytes.Buffer
-}
+// Kind is how an argument is read from the line.
+type Kind int
 
 const (
-	errChatroom       = "chatrooms must begin with '#'"
-	errInvalidArgs    = "invalid arguments"
-	errInvalidCommand = "invalid command"
+	// Word is a single word.
+	Word Kind = iota
+	// Room is a single word beginning with '#'.
+	Room
+	// Words is every remaining word, possibly none.
+	Words
+	// Text is the rest of the line, spaces included.
+	Text
 )
 
-// parseKeyword checks that the next word in the buffer matches exactly the
-// given keyword.
-func (p *parser) parseKeyword(keyword string) error {
-	err := p.matchNext(keyword)
-	if err != nil {
-		return err
-	}
+// field is where in the message an argument is stored.
+type field int
 
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return err
-	}
-
-	if !unicode.IsSpace(rune(ch)) {
-		return errors.New(errInvalidCommand)
-	}
+const (
+	receiver field = iota
+	data
+	args
+)
 
-	return nil
+// arg describes one argument of a command. Once an optional argument is
+// missing every argument after it is too, so only trailing arguments may be
+// optional. Words and Text consume the rest of the line and so must be last.
+type arg struct {
+	name     string
+	kind     Kind
+	field    field
+	optional bool
 }
 
-// matchNext returns whether or not the next bytes in the buffer match exactly
-// the given string.
-func (p *parser) matchNext(word string) error {
-	for i := range word {
-		ch, err := p.buf.ReadByte()
-		if err != nil {
-			return err
-		}
+// Arg describes one argument of a command added with Register. It follows the
+// same rules as the arguments of built in commands.
+type Arg struct {
+	Name     string
+	Kind     Kind
+	Optional bool
+}
 
-		if ch != word[i] {
-			return errors.New(errInvalidCommand)
-		}
-	}
+// spec describes how the line of a command is parsed.
+type spec struct {
+	command int
+	args    []arg
+}
 
-	return nil
+// commands is every command the parser knows, by keyword.
+var commands = map[string]spec{
+	// LOGIN <username> [password]
+	"LOGIN": {message.Login, []arg{
+		{"username", Word, data, false},
+		{"password", Word, args, true},
+	}},
+	// LOGOUT
+	"LOGOUT": {message.Logout, nil},
+	// JOIN #<chatroom> [key]
+	"JOIN": {message.Join, []arg{
+		{"room", Room, data, false},
+		{"key", Word, args, true},
+	}},
+	// PART #<chatroom>
+	"PART": {message.Part, []arg{
+		{"room", Room, data, false},
+	}},
+	// MSG <#chatroom|username> <message-text>
+	"MSG": {message.Msg, []arg{
+		{"receiver", Word, receiver, false},
+		{"text", Text, data, false},
+	}},
+	// PING <token>
+	"PING": {message.Ping, []arg{
+		{"token", Word, data, false},
+	}},
+	// PONG <token>
+	"PONG": {message.Pong, []arg{
+		{"token", Word, data, false},
+	}},
+	// OP #<chatroom> <username>
+	"OP": {message.Op, []arg{
+		{"room", Room, receiver, false},
+		{"username", Word, data, false},
+	}},
+	// DEOP #<chatroom> <username>
+	"DEOP": {message.Deop, []arg{
+		{"room", Room, receiver, false},
+		{"username", Word, data, false},
+	}},
+	// KICK #<chatroom> <username> [reason]
+	"KICK": {message.Kick, []arg{
+		{"room", Room, receiver, false},
+		{"username", Word, data, false},
+		{"reason", Text, args, true},
+	}},
+	// BAN #<chatroom> <mask> [duration]
+	"BAN": {message.Ban, []arg{
+		{"room", Room, receiver, false},
+		{"mask", Word, data, false},
+		{"duration", Word, args, true},
+	}},
+	// UNBAN #<chatroom> <mask>
+	"UNBAN": {message.Unban, []arg{
+		{"room", Room, receiver, false},
+		{"mask", Word, data, false},
+	}},
+	// BANLIST #<chatroom>
+	"BANLIST": {message.Banlist, []arg{
+		{"room", Room, data, false},
+	}},
+	// MODE #<chatroom> [<changes> [arguments...]]
+	"MODE": {message.Mode, []arg{
+		{"room", Room, receiver, false},
+		{"changes", Word, data, true},
+		{"arguments", Words, args, true},
+	}},
+	// INVITE <username> #<chatroom>
+	"INVITE": {message.Invite, []arg{
+		{"username", Word, receiver, false},
+		{"room", Room, data, false},
+	}},
+	// LIST
+	"LIST": {message.List, nil},
+	// OPER <name> <password>
+	"OPER": {message.Oper, []arg{
+		{"name", Word, data, false},
+		{"password", Word, args, false},
+	}},
+	// KILL <username> [reason]
+	"KILL": {message.Kill, []arg{
+		{"username", Word, receiver, false},
+		{"reason", Text, data, true},
+	}},
+	// GLINE <mask> [duration [reason]]
+	"GLINE": {message.Gline, []arg{
+		{"mask", Word, data, false},
+		{"duration", Word, args, true},
+		{"reason", Text, args, true},
+	}},
+	// UNGLINE <mask>
+	"UNGLINE": {message.Ungline, []arg{
+		{"mask", Word, data, false},
+	}},
+	// WALL <message-text>
+	"WALL": {message.Wall, []arg{
+		{"text", Text, data, false},
+	}},
+	// CLOSE #<chatroom> [reason]
+	"CLOSE": {message.Close, []arg{
+		{"room", Room, data, false},
+		{"reason", Text, args, true},
+	}},
+	// RENAME #<chatroom> #<chatroom>
+	"RENAME": {message.Rename, []arg{
+		{"room", Room, receiver, false},
+		{"new room", Room, data, false},
+	}},
+	// MUTE <username> [duration]
+	"MUTE": {message.Mute, []arg{
+		{"username", Word, receiver, false},
+		{"duration", Word, args, true},
+	}},
+	// UNMUTE <username>
+	"UNMUTE": {message.Unmute, []arg{
+		{"username", Word, receiver, false},
+	}},
+	// SHADOWBAN <username> [duration]
+	"SHADOWBAN": {message.Shadowban, []arg{
+		{"username", Word, receiver, false},
+		{"duration", Word, args, true},
+	}},
+	// IGNORE <username>
+	"IGNORE": {message.Ignore, []arg{
+		{"username", Word, receiver, false},
+	}},
+	// UNIGNORE <username>
+	"UNIGNORE": {message.Unignore, []arg{
+		{"username", Word, receiver, false},
+	}},
+	// IGNORELIST
+	"IGNORELIST": {message.Ignorelist, nil},
+	// REGISTER <password>
+	"REGISTER": {message.Register, []arg{
+		{"password", Word, args, false},
+	}},
+	// IDENTIFY <account> <password>
+	"IDENTIFY": {message.Identify, []arg{
+		{"account", Word, data, false},
+		{"password", Word, args, false},
+	}},
+	// AUDIT [count]
+	"AUDIT": {message.Audit, []arg{
+		{"count", Word, data, true},
+	}},
+	// NAMES #<chatroom>
+	"NAMES": {message.Names, []arg{
+		{"room", Room, data, false},
+	}},
+	// CAP [[-]<capability>]
+	"CAP": {message.Cap, []arg{
+		{"capability", Word, data, true},
+	}},
 }
 
-// parseSpace skips all space characters.
-func (p *parser) parseSpace() error {
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return err
+// Register adds a plugin command with the given keyword to those Parse knows.
+// Its message is a message.Plugin with the keyword in its Data and the value
+// of each argument given in its Args. Commands must be registered before
+// lines are parsed.
+func Register(keyword string, grammar []Arg) error {
+	if _, ok := commands[keyword]; ok {
+		return errors.New(errCommandExists + " " + keyword)
 	}
 
-	for unicode.IsSpace(rune(ch)) {
-		ch, err = p.buf.ReadByte()
-		if err != nil {
-			return err
-		}
+	if err := Validate(grammar); err != nil {
+		return err
 	}
 
-	err = p.buf.UnreadByte()
-	if err != nil {
-		return err
+	s := spec{command: message.Plugin}
+	for _, a := range grammar {
+		s.args = append(s.args, arg{a.Name, a.Kind, args, a.Optional})
 	}
+	commands[keyword] = s
 
 	return nil
 }
 
-// parseWord collects all symbols/letters until a space or error is found.
-func (p *parser) parseWord() (string, error) {
-	var result strings.Builder
-
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return "", err
-	} else if unicode.IsSpace(rune(ch)) {
-		return "", errors.New(errInvalidArgs)
-	}
-
-	for !unicode.IsSpace(rune(ch)) {
-		err = result.WriteByte(ch)
-		if err != nil {
-			return "", err
+// Validate checks that the arguments make a grammar Parse can follow: only
+// trailing arguments may be optional, and Words and Text must be last.
+func Validate(grammar []Arg) error {
+	for i, a := range grammar {
+		last := i == len(grammar)-1
+		if (a.Kind == Text || a.Kind == Words) && !last {
+			return errors.New("argument <" + a.Name + "> " + errNotLast)
+		} else if !last && a.Optional && !grammar[i+1].Optional {
+			return errors.New("argument <" + grammar[i+1].Name + "> " + errAfterOptional)
 		}
-
-		ch, err = p.buf.ReadByte()
-		if err != nil {
-			return "", err
-		}
-	}
-
-	err = p.buf.UnreadByte()
-	if err != nil {
-		return "", err
 	}
 
-	return result.String(), nil
+	return nil
 }
 
-// #<chatroom>
-func (p *parser) parseRoom() (string, error) {
-	var result strings.Builder
+// Parse will parse the given line and return its representation. The line
+// must end with a newline, or io.EOF is returned.
+func Parse(b []byte) (message.Message, error) {
+	end := bytes.IndexAny(b, "\r\n")
+	if end < 0 {
+		return message.Message{}, io.EOF
+	} else if len(bytes.TrimSpace(b[end:])) != 0 {
+		return message.Message{}, errors.New(errTrailingData)
+	}
 
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return "", err
-	} else if ch != '#' {
-		p.buf.UnreadByte()
-		return "", errors.New(errChatroom)
+	keyword, rest := nextWord(string(b[:end]))
+	if keyword == "" {
+		return message.Message{}, errors.New(errInvalidCommand)
 	}
 
-	_, err = result.WriteString("#")
-	if err != nil {
-		return "", err
+	s, ok := commands[keyword]
+	if !ok {
+		return message.Message{}, errors.New(errUnknownCommand + " " + keyword)
 	}
 
-	word, err := p.parseWord()
+	m, err := s.parse(rest)
 	if err != nil {
-		return "", err
+		return message.Message{}, err
 	}
 
-	_, err = result.WriteString(word)
-	if err != nil {
-		return "", err
+	if s.command == message.Plugin {
+		m.Data = keyword
 	}
 
-	return result.String(), nil
+	return m, nil
 }
 
-// parseMsgText reads all bytes until a carriage return or line feed is found.
-func (p *parser) parseMsgText() (string, error) {
-	var result strings.Builder
+// parse reads the command's arguments from the rest of its line.
+func (s *spec) parse(rest string) (message.Message, error) {
+	m := message.Message{Command: s.command}
 
-	for {
-		ch, err := p.buf.ReadByte()
-		if err != nil {
-			return "", err
+	for _, a := range s.args {
+		if rest == "" {
+			if a.optional || a.kind == Words {
+				break
+			}
+			return message.Message{}, errors.New(errMissingArg + " <" + a.name + ">")
 		}
 
-		if ch == '\r' || ch == '\n' {
-			break
+		var values []string
+		switch a.kind {
+		case Text:
+			values, rest = []string{rest}, ""
+		case Words:
+			values, rest = strings.FieldsFunc(rest, unicode.IsSpace), ""
+		default:
+			var value string
+			value, rest = nextWord(rest)
+			values = []string{value}
 		}
 
-		err = result.WriteByte(ch)
-		if err != nil {
-			return "", err
+		if a.kind == Room {
+			if !strings.HasPrefix(values[0], "#") {
+				return message.Message{}, errors.New(errChatroom)
+			} else if values[0] == "#" {
+				return message.Message{}, errors.New(errEmptyChatroom)
+			}
 		}
-	}
-
-	return result.String(), nil
-}
 
-// parseOneArgCmd parses a <command> <argument> . The second function argument
-// is a function that determines what strategy to use to parse the argument.
-// E.G. #<chatroom> is parsed different than <username> .
-func (p *parser) parseOneArgCmd(cmd int, parseArg func() (string, error)) error {
-	err := p.parseKeyword(message.StringifyCommand(cmd))
-	if err != nil {
-		return err
-	}
-
-	p.msg.Command = cmd
-
-	err = p.parseSpace()
-	if err != nil {
-		return err
-	}
-
-	p.msg.Data, err = parseArg()
-	if err != nil {
-		return err
-	}
-
-	err = p.parseSpace()
-	if err != io.EOF {
-		return errors.New(errInvalidArgs)
-	}
-
-	return nil
-}
-
-func (p *parser) parseMessage() error {
-	err := p.parseKeyword(message.StringifyCommand(message.Msg))
-	if err != nil {
-		return err
-	}
-
-	p.msg.Command = message.Msg
-
-	err = p.parseSpace()
-	if err != nil {
-		return err
-	}
-
-	p.msg.Receiver, err = p.parseRoom()
-	if err != nil {
-		p.msg.Receiver, err = p.parseWord()
-		if err != nil {
-			return err
+		switch a.field {
+		case receiver:
+			m.Receiver = values[0]
+		case data:
+			m.Data = values[0]
+		case args:
+			m.Args = append(m.Args, values...)
 		}
 	}
 
-	err = p.parseSpace()
-	if err != nil {
-		return err
+	if rest != "" {
+		return message.Message{}, errors.New(errTooManyArgs)
 	}
 
-	p.msg.Data, err = p.parseMsgText()
-	if err != nil {
-		return err
-	}
-
-	err = p.parseSpace()
-	if err != io.EOF {
-		return errors.New(errInvalidArgs)
-	}
-
-	return nil
+	return m, nil
 }
 
-// parseL will determine if input is LOGIN or LOGOUT and parse correctly.
-func (p *parser) parseL() error {
-	err := p.matchNext("LOG")
-	if err != nil {
-		return errors.New(errInvalidCommand)
-	}
+// nextWord splits off the first space separated word of s, returning it and
+// what follows it without leading spaces.
+func nextWord(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
 
-	ch, err := p.buf.ReadByte()
-	if err != nil {
-		return err
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		return s, ""
 	}
 
-	if ch == 'O' {
-		p.buf.UnreadByte()
-		err = p.parseKeyword("OUT")
-		if err != nil {
-			return err
-		}
-
-		p.msg.Command = message.Logout
-	} else {
-		p.buf.UnreadByte()
-		err = p.parseKeyword("IN")
-		if err != nil {
-			return err
-		}
-
-		p.msg.Command = message.Login
-
-		err = p.parseSpace()
-		if err != nil {
-			return err
-		}
-
-		p.msg.Data, err = p.parseWord()
-		if err != nil {
-			return err
-		}
-	}
-
-	err = p.parseSpace()
-	if err != io.EOF {
-		return errors.New(errInvalidCommand)
-	}
-
-	return nil
+	return s[:end], strings.TrimLeftFunc(s[end:], unicode.IsSpace)
 }
+
+const (
+	errAfterOptional  = "is required but follows an optional argument"
+	errChatroom       = "chatrooms must begin with '#'"
+	errCommandExists  = "command already exists:"
+	errEmptyChatroom  = "chatroom name is empty"
+	errInvalidCommand = "invalid command"
+	errMissingArg     = "missing argument"
+	errNotLast        = "consumes the rest of the line but is not last"
+	errTooManyArgs    = "too many arguments"
+	errTrailingData   = "data after end of line"
+	errUnknownCommand = "unknown command"
+)
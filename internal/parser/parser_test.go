@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"io"
 	"testing"
 
@@ -24,6 +25,33 @@ func TestParse(t *testing.T) {
 			}
 		})
 
+		t.Run("should parse with password", func(t *testing.T) {
+			input := []byte("LOGIN alice hunter2\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Login,
+				Receiver: "",
+				Data:     "alice",
+				Args:     []string{"hunter2"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when too many arguments", func(t *testing.T) {
+			input := []byte("LOGIN alice hunter2 extra\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+
 		t.Run("should fail when no newline", func(t *testing.T) {
 			input := []byte("LOGIN alice")
 
@@ -52,6 +80,33 @@ func TestParse(t *testing.T) {
 			}
 		})
 
+		t.Run("should parse with key", func(t *testing.T) {
+			input := []byte("JOIN #chatroom secret\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Join,
+				Receiver: "",
+				Data:     "#chatroom",
+				Args:     []string{"secret"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when too many arguments", func(t *testing.T) {
+			input := []byte("JOIN #chatroom secret extra\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+
 		t.Run("should fail when missing '#'", func(t *testing.T) {
 			input := []byte("JOIN chatroom\r\n")
 
@@ -206,4 +261,992 @@ func TestParse(t *testing.T) {
 			}
 		})
 	})
+
+	t.Run("PING", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("PING abc123\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Ping,
+				Receiver: "",
+				Data:     "abc123",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing <token>", func(t *testing.T) {
+			input := []byte("PING\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("PONG", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("PONG abc123\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Pong,
+				Receiver: "",
+				Data:     "abc123",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when no newline", func(t *testing.T) {
+			input := []byte("PONG abc123")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err != io.EOF {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, EOF)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("OP", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("OP #chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Op,
+				Receiver: "#chatroom",
+				Data:     "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when given text", func(t *testing.T) {
+			input := []byte("OP #chatroom bob please\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("DEOP", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("DEOP #chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Deop,
+				Receiver: "#chatroom",
+				Data:     "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("KICK", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("KICK #chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Kick,
+				Receiver: "#chatroom",
+				Data:     "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with reason", func(t *testing.T) {
+			input := []byte("KICK #chatroom bob stop spamming\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Kick,
+				Receiver: "#chatroom",
+				Data:     "bob",
+				Args:     []string{"stop spamming"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing '#'", func(t *testing.T) {
+			input := []byte("KICK chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+
+		t.Run("should fail when no newline", func(t *testing.T) {
+			input := []byte("KICK #chatroom bob")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err != io.EOF {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, EOF)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("BAN", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("BAN #chatroom spam*@192.0.2.*\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Ban,
+				Receiver: "#chatroom",
+				Data:     "spam*@192.0.2.*",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with duration", func(t *testing.T) {
+			input := []byte("BAN #chatroom bob 1h\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Ban,
+				Receiver: "#chatroom",
+				Data:     "bob",
+				Args:     []string{"1h"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when too many arguments", func(t *testing.T) {
+			input := []byte("BAN #chatroom bob 1h extra\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("UNBAN", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("UNBAN #chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Unban,
+				Receiver: "#chatroom",
+				Data:     "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("BANLIST", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("BANLIST #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Banlist,
+				Receiver: "",
+				Data:     "#chatroom",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("MODE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("MODE #chatroom +kl-m secret 10\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Mode,
+				Receiver: "#chatroom",
+				Data:     "+kl-m",
+				Args:     []string{"secret", "10"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse without changes", func(t *testing.T) {
+			input := []byte("MODE #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Mode,
+				Receiver: "#chatroom",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should not be confused with MSG", func(t *testing.T) {
+			input := []byte("MSG #chatroom MODE\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Msg,
+				Receiver: "#chatroom",
+				Data:     "MODE",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("INVITE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("INVITE bob #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Invite,
+				Receiver: "bob",
+				Data:     "#chatroom",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing '#'", func(t *testing.T) {
+			input := []byte("INVITE bob chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("LIST", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("LIST\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.List,
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when given arguments", func(t *testing.T) {
+			input := []byte("LIST #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("OPER", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("OPER admin hunter2\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Oper,
+				Data:    "admin",
+				Args:    []string{"hunter2"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing password", func(t *testing.T) {
+			input := []byte("OPER admin\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("KILL", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("KILL bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Kill,
+				Receiver: "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with reason", func(t *testing.T) {
+			input := []byte("KILL bob stop spamming\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Kill,
+				Receiver: "bob",
+				Data:     "stop spamming",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should not be confused with KICK", func(t *testing.T) {
+			input := []byte("KICK #chatroom bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Kick,
+				Receiver: "#chatroom",
+				Data:     "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("GLINE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("GLINE bob*\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Gline,
+				Data:    "bob*",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with duration and reason", func(t *testing.T) {
+			input := []byte("GLINE *@10.0.0.0/8 1h go away\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Gline,
+				Data:    "*@10.0.0.0/8",
+				Args:    []string{"1h", "go away"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("UNGLINE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("UNGLINE bob*\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Ungline,
+				Data:    "bob*",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("WALL", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("WALL restarting in 5 minutes\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Wall,
+				Data:    "restarting in 5 minutes",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("CLOSE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("CLOSE #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Close,
+				Data:    "#chatroom",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with reason", func(t *testing.T) {
+			input := []byte("CLOSE #chatroom off topic\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Close,
+				Data:    "#chatroom",
+				Args:    []string{"off topic"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("RENAME", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("RENAME #chatroom #lobby\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Rename,
+				Receiver: "#chatroom",
+				Data:     "#lobby",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing '#'", func(t *testing.T) {
+			input := []byte("RENAME #chatroom lobby\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("MUTE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("MUTE bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Mute,
+				Receiver: "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with duration", func(t *testing.T) {
+			input := []byte("MUTE bob 10m\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Mute,
+				Receiver: "bob",
+				Args:     []string{"10m"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when too many arguments", func(t *testing.T) {
+			input := []byte("MUTE bob 10m extra\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("UNMUTE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("UNMUTE bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Unmute,
+				Receiver: "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when given duration", func(t *testing.T) {
+			input := []byte("UNMUTE bob 10m\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("SHADOWBAN", func(t *testing.T) {
+		t.Run("should parse with duration", func(t *testing.T) {
+			input := []byte("SHADOWBAN bob 1h\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Shadowban,
+				Receiver: "bob",
+				Args:     []string{"1h"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("IGNORE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("IGNORE bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Ignore,
+				Receiver: "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when too many arguments", func(t *testing.T) {
+			input := []byte("IGNORE bob mallory\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("UNIGNORE", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("UNIGNORE bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command:  message.Unignore,
+				Receiver: "bob",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("IGNORELIST", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("IGNORELIST\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Ignorelist,
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when given arguments", func(t *testing.T) {
+			input := []byte("IGNORELIST bob\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("REGISTER", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("REGISTER hunter2\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Register,
+				Args:    []string{"hunter2"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing password", func(t *testing.T) {
+			input := []byte("REGISTER\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("IDENTIFY", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("IDENTIFY alice hunter2\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Identify,
+				Data:    "alice",
+				Args:    []string{"hunter2"},
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail when missing password", func(t *testing.T) {
+			input := []byte("IDENTIFY alice\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{}
+
+			if !actual.Equal(&expect) || err == nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, error)", input, actual, err, expect)
+			}
+		})
+	})
+
+	t.Run("AUDIT", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("AUDIT\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Audit,
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse with count", func(t *testing.T) {
+			input := []byte("AUDIT 50\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Audit,
+				Data:    "50",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("NAMES", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("NAMES #chatroom\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Names,
+				Data:    "#chatroom",
+			}
+
+			if !actual.Equal(&expect) {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should fail without chatroom", func(t *testing.T) {
+			input := []byte("NAMES alice\r\n")
+
+			_, err := Parse(input)
+
+			if err == nil {
+				t.Fatalf("Parse(%#q) = %v, want error", input, err)
+			}
+		})
+	})
+
+	t.Run("CAP", func(t *testing.T) {
+		t.Run("should parse", func(t *testing.T) {
+			input := []byte("CAP -multiline\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Cap,
+				Data:    "-multiline",
+			}
+
+			if !actual.Equal(&expect) || err != nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+
+		t.Run("should parse without capability", func(t *testing.T) {
+			input := []byte("CAP\r\n")
+
+			actual, err := Parse(input)
+			expect := message.Message{
+				Command: message.Cap,
+			}
+
+			if !actual.Equal(&expect) || err != nil {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+			}
+		})
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		tests := []struct {
+			input string
+			err   string
+		}{
+			{"XYZ\r\n", "unknown command XYZ"},
+			{"join #chatroom\r\n", "unknown command join"},
+			{"\r\n", "invalid command"},
+			{"JOIN\r\n", "missing argument <room>"},
+			{"KICK #chatroom\r\n", "missing argument <username>"},
+			{"MSG bob   \r\n", "missing argument <text>"},
+			{"PART chatroom\r\n", "chatrooms must begin with '#'"},
+			{"PART #\r\n", "chatroom name is empty"},
+			{"LOGOUT now\r\n", "too many arguments"},
+			{"LOGIN alice\nLOGOUT\r\n", "data after end of line"},
+		}
+
+		for _, test := range tests {
+			input := []byte(test.input)
+
+			actual, err := Parse(input)
+
+			if !actual.Equal(&message.Message{}) || err == nil || err.Error() != test.err {
+				t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %q)", input, actual, err, message.Message{}, test.err)
+			}
+		}
+	})
+
+	t.Run("should parse tabs and repeated spaces as one space", func(t *testing.T) {
+		input := []byte("KICK\t#chatroom   bob  stop  spamming\r\n")
+
+		actual, err := Parse(input)
+		expect := message.Message{
+			Command:  message.Kick,
+			Receiver: "#chatroom",
+			Data:     "bob",
+			Args:     []string{"stop  spamming"},
+		}
+
+		if !actual.Equal(&expect) || err != nil {
+			t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+		}
+	})
+}
+
+func TestRegister(t *testing.T) {
+	grammar := []Arg{
+		{Name: "room", Kind: Room},
+		{Name: "who", Kind: Word, Optional: true},
+		{Name: "text", Kind: Text, Optional: true},
+	}
+	if err := Register("TOPIC", grammar); err != nil {
+		t.Fatalf("Register() = %v, want %v", err, nil)
+	}
+	defer delete(commands, "TOPIC")
+
+	t.Run("should parse arguments into Args", func(t *testing.T) {
+		input := []byte("TOPIC #room  alice hello,   world\r\n")
+
+		actual, err := Parse(input)
+		expect := message.Message{
+			Command: message.Plugin,
+			Data:    "TOPIC",
+			Args:    []string{"#room", "alice", "hello,   world"},
+		}
+
+		if !actual.Equal(&expect) {
+			t.Fatalf("Parse(%#q) = (%v, %v), want (%v, %v)", input, actual, err, expect, nil)
+		}
+	})
+
+	t.Run("should leave out missing optional arguments", func(t *testing.T) {
+		input := []byte("TOPIC #room\r\n")
+
+		actual, err := Parse(input)
+
+		if err != nil || len(actual.Args) != 1 {
+			t.Fatalf("Parse(%#q) = (%v, %v), want one argument", input, actual, err)
+		}
+	})
+
+	t.Run("should fail like built in commands", func(t *testing.T) {
+		tests := []struct {
+			input  string
+			expect string
+		}{
+			{"TOPIC\r\n", errMissingArg + " <room>"},
+			{"TOPIC room\r\n", errChatroom},
+			{"TOPIC #\r\n", errEmptyChatroom},
+		}
+
+		for _, test := range tests {
+			_, err := Parse([]byte(test.input))
+
+			if err == nil || err.Error() != test.expect {
+				t.Fatalf("Parse(%#q) = %v, want %q", test.input, err, test.expect)
+			}
+		}
+	})
+
+	t.Run("should fail for built in command", func(t *testing.T) {
+		err := Register("JOIN", nil)
+
+		if err == nil {
+			t.Fatalf("Register() = %v, want error", err)
+		}
+	})
+
+	t.Run("should fail on invalid grammar", func(t *testing.T) {
+		err := Register("BAD", []Arg{{Name: "text", Kind: Text}, {Name: "word", Kind: Word}})
+
+		if err == nil {
+			t.Fatalf("Register() = %v, want error", err)
+		}
+	})
+}
+
+func TestCommands(t *testing.T) {
+	for keyword, s := range commands {
+		if message.Lookup(keyword) != s.command {
+			t.Fatalf("%v parses to %v", keyword, message.StringifyCommand(s.command))
+		}
+
+		for i, a := range s.args {
+			last := i == len(s.args)-1
+			if (a.kind == Text || a.kind == Words) && !last {
+				t.Fatalf("%v has <%v> before other arguments", keyword, a.name)
+			} else if !last && a.optional && !s.args[i+1].optional {
+				t.Fatalf("%v has required argument after optional <%v>", keyword, a.name)
+			} else if a.field != args && (a.kind == Words || (i > 0 && s.args[i-1].field == a.field)) {
+				t.Fatalf("%v stores more than one value in the field of <%v>", keyword, a.name)
+			}
+		}
+	}
+
+	for command := message.Login; command < message.Plugin; command++ {
+		if _, ok := commands[message.StringifyCommand(command)]; !ok {
+			t.Fatalf("%v cannot be parsed", message.StringifyCommand(command))
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	inputs := [][]byte{
+		[]byte("LOGIN alice\r\n"),
+		[]byte("JOIN #room key\r\n"),
+		[]byte("MSG #room the quick brown fox jumps over the lazy dog\r\n"),
+		[]byte("MODE #room +l 50\r\n"),
+	}
+
+	for _, input := range inputs {
+		input := input
+		name := string(bytes.Fields(input)[0])
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := Parse(input); err != nil {
+					b.Fatalf("Parse(%#q) = %v, want %v", input, err, nil)
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,197 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Conn is a connection whose addresses are the ones given in a PROXY protocol
+// header rather than those of the proxy it was accepted from.
+type Conn struct {
+	net.Conn
+	r      *bufio.Reader
+	remote net.Addr
+	local  net.Addr
+}
+
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+// v2 headers begin with this signature.
+var signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// v1 headers are at most this long, including the CRLF.
+const maxV1Length = 107
+
+// ReadHeader reads a version 1 or 2 PROXY protocol header from the start of the
+// connection. Headers that do not carry an address, such as v1 UNKNOWN or v2
+// LOCAL, keep the addresses of the underlying connection.
+func ReadHeader(conn net.Conn) (*Conn, error) {
+	c := &Conn{
+		Conn:   conn,
+		r:      bufio.NewReader(conn),
+		remote: conn.RemoteAddr(),
+		local:  conn.LocalAddr(),
+	}
+
+	start, err := c.r.Peek(len(signature))
+	if err == nil && bytes.Equal(start, signature) {
+		return c, c.readV2()
+	}
+
+	start, err = c.r.Peek(6)
+	if err != nil {
+		return nil, err
+	}
+	if string(start) != "PROXY " {
+		return nil, errors.New(errMissingHeader)
+	}
+
+	return c, c.readV1()
+}
+
+// PROXY TCP4 <src> <dst> <src-port> <dst-port>\r\n
+func (c *Conn) readV1() error {
+	var line []byte
+	for {
+		ch, err := c.r.ReadByte()
+		if err != nil {
+			return err
+		}
+
+		line = append(line, ch)
+		if ch == '\n' {
+			break
+		}
+
+		if len(line) >= maxV1Length {
+			return errors.New(errInvalidHeader)
+		}
+	}
+
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return errors.New(errInvalidHeader)
+	}
+
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil
+	}
+
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return errors.New(errInvalidHeader)
+	}
+
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return err
+	}
+
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return err
+	}
+
+	c.remote = src
+	c.local = dst
+
+	return nil
+}
+
+func parseV1Addr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, errors.New(errInvalidHeader)
+	}
+
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, errors.New(errInvalidHeader)
+	}
+
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// <signature> <version/command> <family/protocol> <length> <addresses> <TLVs>
+func (c *Conn) readV2() error {
+	header := make([]byte, len(signature)+4)
+	if _, err := io.ReadFull(c.r, header); err != nil {
+		return err
+	}
+
+	verCmd := header[12]
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+
+	if verCmd>>4 != 2 {
+		return errors.New(errInvalidHeader)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+
+	const (
+		cmdLocal = 0
+		cmdProxy = 1
+	)
+
+	switch verCmd & 0x0f {
+	case cmdLocal:
+		return nil
+	case cmdProxy:
+	default:
+		return errors.New(errInvalidHeader)
+	}
+
+	const (
+		familyInet  = 1
+		familyInet6 = 2
+	)
+
+	var size int
+	switch family >> 4 {
+	case familyInet:
+		size = net.IPv4len
+	case familyInet6:
+		size = net.IPv6len
+	default:
+		// Addresses the server has no use for, such as Unix sockets.
+		return nil
+	}
+
+	if len(payload) < 2*size+4 {
+		return errors.New(errInvalidHeader)
+	}
+
+	srcIP := net.IP(payload[:size])
+	dstIP := net.IP(payload[size : 2*size])
+	srcPort := binary.BigEndian.Uint16(payload[2*size:])
+	dstPort := binary.BigEndian.Uint16(payload[2*size+2:])
+
+	c.remote = &net.TCPAddr{IP: srcIP, Port: int(srcPort)}
+	c.local = &net.TCPAddr{IP: dstIP, Port: int(dstPort)}
+
+	return nil
+}
+
+const (
+	errInvalidHeader = "invalid PROXY protocol header"
+	errMissingHeader = "missing PROXY protocol header"
+)
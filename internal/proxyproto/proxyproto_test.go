@@ -0,0 +1,109 @@
+package proxyproto
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// send writes the given bytes to one end of a pipe and returns the other end.
+func send(b []byte) net.Conn {
+	client, server := net.Pipe()
+	go func() {
+		client.Write(b)
+		client.Close()
+	}()
+	return server
+}
+
+func v2Header(verCmd byte, family byte, addrs []byte) []byte {
+	b := append([]byte{}, signature...)
+	b = append(b, verCmd, family, 0, 0)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(addrs)))
+	return append(b, addrs...)
+}
+
+func TestReadHeader(t *testing.T) {
+	t.Run("should read v1 header", func(t *testing.T) {
+		conn := send([]byte("PROXY TCP4 192.0.2.1 198.51.100.1 56324 6667\r\nLOGIN alice\r\n"))
+
+		c, err := ReadHeader(conn)
+		if err != nil {
+			t.Fatalf("ReadHeader() = %v, want %v", err, nil)
+		}
+		rest, _ := io.ReadAll(c)
+
+		expect := "192.0.2.1:56324"
+		if c.RemoteAddr().String() != expect || string(rest) != "LOGIN alice\r\n" {
+			t.Fatalf("RemoteAddr() = %v, want %v; read %#q", c.RemoteAddr(), expect, rest)
+		}
+	})
+
+	t.Run("should read v1 IPv6 header", func(t *testing.T) {
+		conn := send([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 56324 6667\r\n"))
+
+		c, err := ReadHeader(conn)
+
+		expect := "[2001:db8::1]:56324"
+		if err != nil || c.RemoteAddr().String() != expect {
+			t.Fatalf("ReadHeader() = (%v, %v), want (%v, %v)", c.RemoteAddr(), err, expect, nil)
+		}
+	})
+
+	t.Run("should keep address on v1 UNKNOWN", func(t *testing.T) {
+		conn := send([]byte("PROXY UNKNOWN\r\n"))
+
+		c, err := ReadHeader(conn)
+
+		if err != nil || c.RemoteAddr() != conn.RemoteAddr() {
+			t.Fatalf("ReadHeader() = (%v, %v), want (%v, %v)", c.RemoteAddr(), err, conn.RemoteAddr(), nil)
+		}
+	})
+
+	t.Run("should fail on malformed v1 header", func(t *testing.T) {
+		conn := send([]byte("PROXY TCP4 192.0.2.1 56324 6667\r\n"))
+
+		_, err := ReadHeader(conn)
+
+		if err == nil {
+			t.Fatalf("ReadHeader() = %v, want error", err)
+		}
+	})
+
+	t.Run("should read v2 header", func(t *testing.T) {
+		addrs := []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x1a, 0x0b}
+		conn := send(append(v2Header(0x21, 0x11, addrs), "LOGIN alice\r\n"...))
+
+		c, err := ReadHeader(conn)
+		if err != nil {
+			t.Fatalf("ReadHeader() = %v, want %v", err, nil)
+		}
+		rest, _ := io.ReadAll(c)
+
+		expect := "192.0.2.1:56324"
+		if c.RemoteAddr().String() != expect || string(rest) != "LOGIN alice\r\n" {
+			t.Fatalf("RemoteAddr() = %v, want %v; read %#q", c.RemoteAddr(), expect, rest)
+		}
+	})
+
+	t.Run("should keep address on v2 LOCAL", func(t *testing.T) {
+		conn := send(v2Header(0x20, 0x00, nil))
+
+		c, err := ReadHeader(conn)
+
+		if err != nil || c.RemoteAddr() != conn.RemoteAddr() {
+			t.Fatalf("ReadHeader() = (%v, %v), want (%v, %v)", c.RemoteAddr(), err, conn.RemoteAddr(), nil)
+		}
+	})
+
+	t.Run("should fail when header is missing", func(t *testing.T) {
+		conn := send([]byte("LOGIN alice\r\n"))
+
+		_, err := ReadHeader(conn)
+
+		if err == nil {
+			t.Fatalf("ReadHeader() = %v, want error", err)
+		}
+	})
+}
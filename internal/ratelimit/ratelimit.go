@@ -0,0 +1,217 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Command classes. Each class has its own token bucket so that, for example,
+// a burst of JOINs does not use up the tokens needed to send messages.
+const (
+	ClassMsg     = "msg"
+	ClassRoom    = "room"
+	ClassDefault = "default"
+)
+
+// Verdict is the outcome of asking a Limiter whether a command may run.
+type Verdict int
+
+const (
+	Allow Verdict = iota
+	Deny
+	Disconnect
+)
+
+// Bucket is a token bucket that refills at a fixed rate up to its burst size.
+type Bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewBucket returns a full bucket. A burst below one is taken as one, since a
+// bucket that cannot hold a token would deny everything.
+func NewBucket(r config.Rate) *Bucket {
+	burst := float64(r.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Bucket{
+		rate:   r.PerSecond,
+		burst:  burst,
+		tokens: burst,
+	}
+}
+
+// Take refills the bucket up to now and then takes a token from it. Returns
+// whether a token was available.
+func (b *Bucket) Take(now time.Time) bool {
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// Full returns whether the bucket will have refilled completely by now, in
+// which case it is no different from a new one.
+func (b *Bucket) Full(now time.Time) bool {
+	return b.last.IsZero() || b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// Limiter tracks token buckets and offenses for connections and accounts.
+type Limiter struct {
+	mu       sync.Mutex
+	cfg      config.RateLimit
+	accounts map[string]*record
+	now      func() time.Time
+}
+
+// Session is the rate limiting state of a single connection.
+type Session struct {
+	limiter *Limiter
+	record  record
+}
+
+// record is the set of buckets and the offense history of either a
+// connection or an account.
+type record struct {
+	buckets    map[string]*Bucket
+	strikes    int
+	since      time.Time
+	mutedUntil time.Time
+	lastSeen   time.Time
+}
+
+func New(cfg config.RateLimit) *Limiter {
+	return &Limiter{
+		cfg:      cfg,
+		accounts: make(map[string]*record),
+		now:      time.Now,
+	}
+}
+
+// NewSession returns the rate limiting state for a new connection.
+func (l *Limiter) NewSession() *Session {
+	return &Session{
+		limiter: l,
+		record:  record{buckets: make(map[string]*Bucket)},
+	}
+}
+
+// Allow determines whether a command of the given class may run. When account
+// is not empty the account's buckets must also allow it, and offenses are
+// recorded against the account so that reconnecting does not reset them.
+func (s *Session) Allow(class string, account string) Verdict {
+	l := s.limiter
+	if !l.cfg.Enabled {
+		return Allow
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	offender := &s.record
+	var acct *record
+	if account != "" {
+		acct = l.account(account, now)
+		offender = acct
+	}
+
+	if class == ClassMsg && now.Before(offender.mutedUntil) {
+		return l.strike(offender, now)
+	}
+
+	ok := s.record.take(l.cfg.Connection, class, now)
+	if ok && acct != nil {
+		ok = acct.take(l.cfg.Account, class, now)
+	}
+
+	if ok {
+		return Allow
+	}
+
+	return l.strike(offender, now)
+}
+
+// account returns the record of the given account, creating it if needed.
+// Records of accounts that have been quiet for a while are dropped.
+func (l *Limiter) account(name string, now time.Time) *record {
+	r, ok := l.accounts[name]
+	if !ok {
+		l.prune(now)
+
+		r = &record{buckets: make(map[string]*Bucket)}
+		l.accounts[name] = r
+	}
+	r.lastSeen = now
+
+	return r
+}
+
+func (l *Limiter) prune(now time.Time) {
+	const idle = 10 * time.Minute
+
+	for name, r := range l.accounts {
+		if now.Sub(r.lastSeen) > idle && now.After(r.mutedUntil) {
+			delete(l.accounts, name)
+		}
+	}
+}
+
+// strike records an offense and escalates to a temporary mute and then to a
+// disconnect for repeat offenders.
+func (l *Limiter) strike(r *record, now time.Time) Verdict {
+	if now.Sub(r.since) > time.Duration(l.cfg.StrikeWindow) {
+		r.since = now
+		r.strikes = 0
+	}
+	r.strikes++
+
+	if l.cfg.DisconnectAfter > 0 && r.strikes >= l.cfg.DisconnectAfter {
+		r.strikes = 0
+		return Disconnect
+	}
+
+	if l.cfg.MuteAfter > 0 && r.strikes == l.cfg.MuteAfter {
+		r.mutedUntil = now.Add(time.Duration(l.cfg.MuteFor))
+	}
+
+	return Deny
+}
+
+// take takes a token from the bucket of the given class, falling back to the
+// default class. Classes without a configured rate are not limited.
+func (r *record) take(rates map[string]config.Rate, class string, now time.Time) bool {
+	rate, ok := rates[class]
+	if !ok {
+		class = ClassDefault
+		rate, ok = rates[class]
+		if !ok {
+			return true
+		}
+	}
+
+	b, ok := r.buckets[class]
+	if !ok {
+		b = NewBucket(rate)
+		r.buckets[class] = b
+	}
+
+	return b.Take(now)
+}
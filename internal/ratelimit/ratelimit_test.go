@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// clock is a fake time source that only moves when told to.
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func newLimiter(cfg config.RateLimit) (*Limiter, *clock) {
+	c := &clock{t: time.Unix(1000, 0)}
+	l := New(cfg)
+	l.now = c.now
+	return l, c
+}
+
+func TestBucket(t *testing.T) {
+	t.Run("should allow burst then refill", func(t *testing.T) {
+		b := NewBucket(config.Rate{PerSecond: 1, Burst: 2})
+		now := time.Unix(1000, 0)
+
+		first, second, third := b.Take(now), b.Take(now), b.Take(now)
+		refilled := b.Take(now.Add(time.Second))
+
+		if !first || !second || third || !refilled {
+			t.Fatalf("Take() = %v %v %v %v, want true true false true", first, second, third, refilled)
+		}
+	})
+
+	t.Run("should hold one token without a burst", func(t *testing.T) {
+		b := NewBucket(config.Rate{PerSecond: 1, Burst: 0})
+		now := time.Unix(1000, 0)
+
+		first, second := b.Take(now), b.Take(now)
+		refilled := b.Take(now.Add(time.Second))
+
+		if !first || second || !refilled {
+			t.Fatalf("Take() = %v %v %v, want true false true", first, second, refilled)
+		}
+	})
+}
+
+func TestAllow(t *testing.T) {
+	cfg := config.RateLimit{
+		Enabled:         true,
+		Connection:      map[string]config.Rate{ClassMsg: {PerSecond: 1, Burst: 1}},
+		Account:         map[string]config.Rate{ClassMsg: {PerSecond: 1, Burst: 1}},
+		StrikeWindow:    config.Duration(time.Minute),
+		MuteAfter:       2,
+		MuteFor:         config.Duration(time.Minute),
+		DisconnectAfter: 4,
+	}
+
+	t.Run("should deny when bucket is empty", func(t *testing.T) {
+		l, _ := newLimiter(cfg)
+		s := l.NewSession()
+
+		first, second := s.Allow(ClassMsg, ""), s.Allow(ClassMsg, "")
+
+		if first != Allow || second != Deny {
+			t.Fatalf("Allow() = %v %v, want %v %v", first, second, Allow, Deny)
+		}
+	})
+
+	t.Run("should not limit classes without a rate", func(t *testing.T) {
+		l, _ := newLimiter(cfg)
+		s := l.NewSession()
+
+		for i := 0; i < 10; i++ {
+			if v := s.Allow(ClassRoom, ""); v != Allow {
+				t.Fatalf("Allow() = %v, want %v", v, Allow)
+			}
+		}
+	})
+
+	t.Run("should share account buckets between connections", func(t *testing.T) {
+		l, _ := newLimiter(cfg)
+
+		first := l.NewSession().Allow(ClassMsg, "alice")
+		second := l.NewSession().Allow(ClassMsg, "alice")
+
+		if first != Allow || second != Deny {
+			t.Fatalf("Allow() = %v %v, want %v %v", first, second, Allow, Deny)
+		}
+	})
+
+	t.Run("should mute repeat offenders", func(t *testing.T) {
+		l, c := newLimiter(cfg)
+		s := l.NewSession()
+
+		s.Allow(ClassMsg, "alice")
+		s.Allow(ClassMsg, "alice")
+		s.Allow(ClassMsg, "alice")
+		c.t = c.t.Add(10 * time.Second)
+		v := s.Allow(ClassMsg, "alice")
+
+		if v != Deny {
+			t.Fatalf("Allow() = %v, want %v", v, Deny)
+		}
+	})
+
+	t.Run("should disconnect repeat offenders", func(t *testing.T) {
+		l, _ := newLimiter(cfg)
+		s := l.NewSession()
+
+		var v Verdict
+		for i := 0; i < 5; i++ {
+			v = s.Allow(ClassMsg, "")
+		}
+
+		if v != Disconnect {
+			t.Fatalf("Allow() = %v, want %v", v, Disconnect)
+		}
+	})
+
+	t.Run("should allow everything when disabled", func(t *testing.T) {
+		disabled := cfg
+		disabled.Enabled = false
+		l, _ := newLimiter(disabled)
+		s := l.NewSession()
+
+		for i := 0; i < 10; i++ {
+			if v := s.Allow(ClassMsg, ""); v != Allow {
+				t.Fatalf("Allow() = %v, want %v", v, Allow)
+			}
+		}
+	})
+}
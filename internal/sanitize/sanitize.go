@@ -0,0 +1,181 @@
+// Package sanitize cleans the text users send before it reaches the terminals
+// of other users.
+package sanitize
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Sanitizer cleans text as set in its configuration.
+type Sanitizer struct {
+	invalidUTF8 string
+	controls    string
+	bidi        string
+}
+
+// New returns a Sanitizer for the given configuration.
+func New(cfg config.Sanitize) (*Sanitizer, error) {
+	switch cfg.InvalidUTF8 {
+	case "", "reject", "repair":
+	default:
+		return nil, errors.New(errUnknownAction + cfg.InvalidUTF8)
+	}
+
+	for _, action := range []string{cfg.Controls, cfg.Bidi} {
+		switch action {
+		case "", "strip", "escape":
+		default:
+			return nil, errors.New(errUnknownAction + action)
+		}
+	}
+
+	return &Sanitizer{invalidUTF8: cfg.InvalidUTF8, controls: cfg.Controls, bidi: cfg.Bidi}, nil
+}
+
+// String returns the text cleaned, or an error when it is rejected.
+func (s *Sanitizer) String(text string) (string, error) {
+	if !utf8.ValidString(text) {
+		switch s.invalidUTF8 {
+		case "reject":
+			return "", errors.New(errInvalidUTF8)
+		case "repair":
+			text = strings.ToValidUTF8(text, "\uFFFD")
+		}
+	}
+
+	if (s.controls == "" && s.bidi == "") || clean(text) {
+		return text, nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		action := ""
+		if isControl(r) {
+			action = s.controls
+		} else if isBidi(r) {
+			action = s.bidi
+		}
+
+		switch action {
+		case "strip":
+			if isControl(r) {
+				size = sequenceLen(text[i:])
+			}
+		case "escape":
+			fmt.Fprintf(&b, "<U+%04X>", r)
+		default:
+			b.WriteString(text[i : i+size])
+		}
+
+		i += size
+	}
+
+	return b.String(), nil
+}
+
+// clean returns whether the text has no control or bidirectional formatting
+// characters.
+func clean(text string) bool {
+	for _, r := range text {
+		if isControl(r) || isBidi(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// isControl returns whether the rune is a C0 or C1 control character other
+// than a tab or newline. Newlines only appear in texts of the multiline
+// capability.
+func isControl(r rune) bool {
+	return (r < 0x20 && r != '\t' && r != '\n') || (r >= 0x7f && r <= 0x9f)
+}
+
+// isBidi returns whether the rune is a bidirectional formatting character,
+// which can make text display in a different order than it is read.
+func isBidi(r rune) bool {
+	switch {
+	case r == 0x061c, r == 0x200e, r == 0x200f:
+		return true
+	case r >= 0x202a && r <= 0x202e:
+		return true
+	case r >= 0x2066 && r <= 0x2069:
+		return true
+	}
+	return false
+}
+
+// sequenceLen returns the length in bytes of the control sequence that the
+// text begins with. An ANSI escape sequence is counted whole, and any other
+// control character alone.
+func sequenceLen(text string) int {
+	r, size := utf8.DecodeRuneInString(text)
+
+	// Control sequences begin with CSI, either ESC [ or the C1 character.
+	// Strings such as OSC run until a BEL or ST.
+	switch {
+	case r == 0x1b && len(text) > 1 && text[1] == '[', r == 0x9b:
+		if r == 0x1b {
+			size = 2
+		}
+		return size + csiLen(text[size:])
+	case r == 0x1b && len(text) > 1 && strings.IndexByte("]PX^_", text[1]) >= 0:
+		return 2 + stringLen(text[2:])
+	case r == 0x90, r == 0x98, r == 0x9d, r == 0x9e, r == 0x9f:
+		return size + stringLen(text[size:])
+	case r == 0x1b:
+		// ESC, any intermediate bytes and a final byte.
+		n := 1
+		for n < len(text) && text[n] >= 0x20 && text[n] <= 0x2f {
+			n++
+		}
+		if n < len(text) && text[n] >= 0x30 && text[n] <= 0x7e {
+			n++
+		}
+		return n
+	}
+
+	return size
+}
+
+// csiLen returns the length of the parameter, intermediate and final bytes of
+// a control sequence.
+func csiLen(text string) int {
+	for n := 0; n < len(text); n++ {
+		switch {
+		case text[n] >= 0x40 && text[n] <= 0x7e:
+			return n + 1
+		case text[n] < 0x20 || text[n] > 0x3f:
+			return n
+		}
+	}
+	return len(text)
+}
+
+// stringLen returns the length of a control string up to and including the BEL
+// or ST that ends it.
+func stringLen(text string) int {
+	for n := 0; n < len(text); {
+		r, size := utf8.DecodeRuneInString(text[n:])
+		switch {
+		case r == 0x07, r == 0x9c:
+			return n + size
+		case r == 0x1b && n+1 < len(text) && text[n+1] == '\\':
+			return n + 2
+		}
+		n += size
+	}
+	return len(text)
+}
+
+const (
+	errInvalidUTF8   = "invalid UTF-8"
+	errUnknownAction = "unknown sanitize action: "
+)
@@ -0,0 +1,111 @@
+package sanitize
+
+import (
+	"testing"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+func newSanitizer(t *testing.T, cfg config.Sanitize) *Sanitizer {
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New() = %v, want %v", err, nil)
+	}
+	return s
+}
+
+func TestNew(t *testing.T) {
+	t.Run("should fail on unknown action", func(t *testing.T) {
+		for _, cfg := range []config.Sanitize{{InvalidUTF8: "strip"}, {Controls: "repair"}, {Bidi: "ignore"}} {
+			if _, err := New(cfg); err == nil {
+				t.Fatalf("New(%+v) = %v, want error", cfg, err)
+			}
+		}
+	})
+}
+
+func TestString(t *testing.T) {
+	t.Run("should leave text when not configured", func(t *testing.T) {
+		s := newSanitizer(t, config.Sanitize{})
+		text := "\x1b[31mred\xff\u202e"
+
+		actual, err := s.String(text)
+
+		if actual != text || err != nil {
+			t.Fatalf("String(%q) = (%q, %v), want (%q, %v)", text, actual, err, text, nil)
+		}
+	})
+
+	t.Run("should reject invalid UTF-8", func(t *testing.T) {
+		s := newSanitizer(t, config.Sanitize{InvalidUTF8: "reject"})
+
+		_, err := s.String("caf\xe9")
+
+		if err == nil || err.Error() != "invalid UTF-8" {
+			t.Fatalf("String() = %v, want %q", err, "invalid UTF-8")
+		}
+	})
+
+	t.Run("should repair invalid UTF-8", func(t *testing.T) {
+		s := newSanitizer(t, config.Sanitize{InvalidUTF8: "repair"})
+
+		actual, err := s.String("caf\xe9 ok")
+
+		expect := "caf\uFFFD ok"
+		if actual != expect || err != nil {
+			t.Fatalf("String() = (%q, %v), want (%q, %v)", actual, err, expect, nil)
+		}
+	})
+
+	t.Run("should strip controls and escape sequences", func(t *testing.T) {
+		s := newSanitizer(t, config.Sanitize{Controls: "strip"})
+		tests := []struct {
+			text   string
+			expect string
+		}{
+			{"\x1b[1;31mred\x1b[0m", "red"},
+			{"\x1b]0;pwned\x07title", "title"},
+			{"\x1b]8;;http://example.com\x1b\\link\x1b]8;;\x1b\\", "link"},
+			{"\u009b2Jclear", "clear"},
+			{"\x1b(Bcharset", "charset"},
+			{"bell\x07 and\x00 nul\x7f", "bell and nul"},
+			{"keep\ttabs\nand newlines", "keep\ttabs\nand newlines"},
+			{"café 世界", "café 世界"},
+			{"unfinished \x1b[", "unfinished "},
+		}
+
+		for _, test := range tests {
+			actual, err := s.String(test.text)
+
+			if actual != test.expect || err != nil {
+				t.Fatalf("String(%q) = (%q, %v), want (%q, %v)", test.text, actual, err, test.expect, nil)
+			}
+		}
+	})
+
+	t.Run("should escape controls", func(t *testing.T) {
+		s := newSanitizer(t, config.Sanitize{Controls: "escape"})
+
+		actual, err := s.String("\x1b[31mred\u0085")
+
+		expect := "<U+001B>[31mred<U+0085>"
+		if actual != expect || err != nil {
+			t.Fatalf("String() = (%q, %v), want (%q, %v)", actual, err, expect, nil)
+		}
+	})
+
+	t.Run("should neutralize bidi controls only when configured", func(t *testing.T) {
+		text := "user\u202egpj.exe"
+		keep := newSanitizer(t, config.Sanitize{Controls: "strip"})
+		strip := newSanitizer(t, config.Sanitize{Bidi: "strip"})
+		escape := newSanitizer(t, config.Sanitize{Bidi: "escape"})
+
+		kept, _ := keep.String(text)
+		stripped, _ := strip.String(text)
+		escaped, _ := escape.String(text)
+
+		if kept != text || stripped != "usergpj.exe" || escaped != "user<U+202E>gpj.exe" {
+			t.Fatalf("String(%q) = %q %q %q", text, kept, stripped, escaped)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package spam
+
+import (
+	"errors"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// Response is what should happen to a message.
+type Response int
+
+const (
+	// Allow delivers the message.
+	Allow Response = iota
+	// Reject refuses the message.
+	Reject
+	// Mute refuses the message and mutes its sender.
+	Mute
+	// Notify delivers the message and tells server operators about it.
+	Notify
+)
+
+// sweepEvery is how many checks pass between sweeps of forgotten senders and
+// fingerprints.
+const sweepEvery = 1024
+
+// Detector remembers the fingerprints of recent messages per sender and
+// server wide.
+type Detector struct {
+	mu       sync.Mutex
+	cfg      config.Spam
+	response Response
+	users    map[string][]sighting
+	server   map[uint64][]sighting
+	checks   int
+	now      func() time.Time
+}
+
+// sighting is a message with the given fingerprint sent by user at a time.
+type sighting struct {
+	fingerprint uint64
+	user        string
+	at          time.Time
+}
+
+func New(cfg config.Spam) (*Detector, error) {
+	d := &Detector{
+		cfg:    cfg,
+		users:  make(map[string][]sighting),
+		server: make(map[uint64][]sighting),
+		now:    time.Now,
+	}
+
+	switch cfg.Action {
+	case "", "reject":
+		d.response = Reject
+	case "mute":
+		d.response = Mute
+	case "notify":
+		d.response = Notify
+	default:
+		return nil, errors.New(errUnknownAction + cfg.Action)
+	}
+
+	return d, nil
+}
+
+// MuteFor is how long senders are muted for with the Mute response.
+func (d *Detector) MuteFor() time.Duration {
+	return time.Duration(d.cfg.MuteFor)
+}
+
+// Check records that the user sent the text and returns the response to it.
+func (d *Detector) Check(user string, text string) Response {
+	if !d.cfg.Enabled {
+		return Allow
+	}
+
+	fp, ok := fingerprint(text, d.cfg.MinLength)
+	if !ok {
+		return Allow
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	since := now.Add(-time.Duration(d.cfg.Window))
+
+	d.checks++
+	if d.checks%sweepEvery == 0 {
+		d.sweep(since)
+	}
+
+	s := sighting{fingerprint: fp, user: user, at: now}
+	d.users[user] = append(recent(d.users[user], since), s)
+	d.server[fp] = append(recent(d.server[fp], since), s)
+
+	repeats := 0
+	for _, seen := range d.users[user] {
+		if seen.fingerprint == fp {
+			repeats++
+		}
+	}
+
+	senders := make(map[string]bool)
+	for _, seen := range d.server[fp] {
+		senders[seen.user] = true
+	}
+
+	if (d.cfg.UserRepeats > 0 && repeats >= d.cfg.UserRepeats) ||
+		(d.cfg.ServerRepeats > 0 && len(senders) >= d.cfg.ServerRepeats) {
+		return d.response
+	}
+
+	return Allow
+}
+
+// sweep forgets sightings older than since.
+func (d *Detector) sweep(since time.Time) {
+	for user, seen := range d.users {
+		if seen = recent(seen, since); len(seen) == 0 {
+			delete(d.users, user)
+		} else {
+			d.users[user] = seen
+		}
+	}
+
+	for fp, seen := range d.server {
+		if seen = recent(seen, since); len(seen) == 0 {
+			delete(d.server, fp)
+		} else {
+			d.server[fp] = seen
+		}
+	}
+}
+
+// recent returns the sightings at or after since. Sightings are kept in the
+// order they happened.
+func recent(seen []sighting, since time.Time) []sighting {
+	i := 0
+	for i < len(seen) && seen[i].at.Before(since) {
+		i++
+	}
+	return seen[i:]
+}
+
+// fingerprint returns a hash of the text that is the same for texts that
+// differ only in case, spacing, punctuation or repeated letters. Returns false
+// when fewer than minLength letters and digits are left to compare.
+func fingerprint(text string, minLength int) (uint64, bool) {
+	var b strings.Builder
+	var last rune
+	n := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			continue
+		}
+
+		r = unicode.ToLower(r)
+		n++
+		if r != last {
+			b.WriteRune(r)
+			last = r
+		}
+	}
+
+	if n < minLength || n == 0 {
+		return 0, false
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(b.String()))
+	return h.Sum64(), true
+}
+
+const (
+	errUnknownAction = "unknown spam action: "
+)
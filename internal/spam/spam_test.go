@@ -0,0 +1,98 @@
+package spam
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// newDetector returns a detector with the given response whose clock is
+// controlled by the returned pointer.
+func newDetector(t *testing.T, action string) (*Detector, *time.Time) {
+	d, err := New(config.Spam{
+		Enabled:       true,
+		Window:        config.Duration(30 * time.Second),
+		UserRepeats:   3,
+		ServerRepeats: 3,
+		MinLength:     8,
+		Action:        action,
+	})
+	if err != nil {
+		t.Fatalf("New() = %v, want %v", err, nil)
+	}
+
+	now := time.Now()
+	d.now = func() time.Time { return now }
+
+	return d, &now
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("should flag same user repeating near identical text", func(t *testing.T) {
+		d, _ := newDetector(t, "reject")
+
+		d.Check("alice", "Buy cheap watches!")
+		d.Check("alice", "buy   CHEAP watches")
+		res := d.Check("alice", "buy cheaaap watches!!!")
+
+		if res != Reject {
+			t.Fatalf("Check() = %v, want %v", res, Reject)
+		}
+	})
+
+	t.Run("should flag many users sending same text", func(t *testing.T) {
+		d, _ := newDetector(t, "notify")
+
+		d.Check("alice", "join my server now")
+		d.Check("bob", "join my server now")
+		res := d.Check("carol", "join my server now")
+
+		if res != Notify {
+			t.Fatalf("Check() = %v, want %v", res, Notify)
+		}
+	})
+
+	t.Run("should forget text outside window", func(t *testing.T) {
+		d, now := newDetector(t, "mute")
+
+		d.Check("alice", "Buy cheap watches!")
+		d.Check("alice", "Buy cheap watches!")
+		*now = now.Add(31 * time.Second)
+		res := d.Check("alice", "Buy cheap watches!")
+
+		if res != Allow {
+			t.Fatalf("Check() = %v, want %v", res, Allow)
+		}
+	})
+
+	t.Run("should ignore short text", func(t *testing.T) {
+		d, _ := newDetector(t, "reject")
+
+		d.Check("alice", "lol")
+		d.Check("alice", "lol")
+		res := d.Check("alice", "lol")
+
+		if res != Allow {
+			t.Fatalf("Check() = %v, want %v", res, Allow)
+		}
+	})
+
+	t.Run("should allow everything when disabled", func(t *testing.T) {
+		d, _ := New(config.Spam{UserRepeats: 1})
+
+		res := d.Check("alice", "Buy cheap watches!")
+
+		if res != Allow {
+			t.Fatalf("Check() = %v, want %v", res, Allow)
+		}
+	})
+
+	t.Run("should fail on unknown action", func(t *testing.T) {
+		_, err := New(config.Spam{Action: "explode"})
+
+		if err == nil {
+			t.Fatalf("New() = %v, want error", err)
+		}
+	})
+}
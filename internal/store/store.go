@@ -0,0 +1,159 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is a key value store kept in a single JSON file. Every change rewrites
+// the whole file, so it suits small amounts of rarely changing data such as
+// per-account settings. The file is written by a goroutine of its own so that
+// saving never waits on the disk.
+type File struct {
+	mu     sync.Mutex
+	path   string
+	data   map[string]json.RawMessage
+	err    error
+	closed bool
+
+	// dirty is signalled when data has changed since the file was last
+	// written. done is closed when the writer has stopped.
+	dirty chan struct{}
+	done  chan struct{}
+}
+
+// Open reads the store at the given path, starting empty if the file does not
+// exist yet.
+func Open(path string) (*File, error) {
+	f := &File{
+		path:  path,
+		data:  make(map[string]json.RawMessage),
+		dirty: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		if err = json.Unmarshal(b, &f.data); err != nil {
+			return nil, err
+		}
+	}
+
+	go f.run()
+
+	return f, nil
+}
+
+// Load decodes the value stored under key into v. Returns false if there is
+// no such key.
+func (f *File) Load(key string, v interface{}) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	raw, ok := f.data[key]
+	if !ok {
+		return false, nil
+	}
+
+	return true, json.Unmarshal(raw, v)
+}
+
+// Save stores v under key, and the store is written to its file soon after. A
+// nil v removes the key. The error, if any, may be from an earlier write.
+func (f *File) Save(key string, v interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return errors.New(errClosed)
+	}
+
+	if v == nil {
+		delete(f.data, key)
+	} else {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		f.data[key] = raw
+	}
+
+	select {
+	case f.dirty <- struct{}{}:
+	default:
+	}
+
+	err := f.err
+	f.err = nil
+	return err
+}
+
+// Close writes any unsaved changes and stops the writer.
+func (f *File) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.dirty)
+	f.mu.Unlock()
+
+	<-f.done
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.err
+}
+
+// run writes the file each time the store changes until it is closed.
+func (f *File) run() {
+	defer close(f.done)
+
+	for range f.dirty {
+		f.mu.Lock()
+		b, err := json.MarshalIndent(f.data, "", "  ")
+		f.mu.Unlock()
+
+		if err == nil {
+			err = f.write(b)
+		}
+
+		if err != nil {
+			f.mu.Lock()
+			f.err = err
+			f.mu.Unlock()
+		}
+	}
+}
+
+// write replaces the file with the given contents. They are written to a
+// temporary file first so a crash never leaves the file half written.
+func (f *File) write(b []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+const (
+	errClosed = "store closed"
+)
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFile(t *testing.T) {
+	t.Run("should start empty when file does not exist", func(t *testing.T) {
+		f, err := Open(filepath.Join(t.TempDir(), "waddle.json"))
+		if err != nil {
+			t.Fatalf("Open() = %v, want %v", err, nil)
+		}
+
+		var v []string
+		ok, err := f.Load("missing", &v)
+
+		if ok || err != nil {
+			t.Fatalf("Load() = (%v, %v), want (%v, %v)", ok, err, false, nil)
+		}
+	})
+
+	t.Run("should keep saved values after reopening", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "waddle.json")
+		f, _ := Open(path)
+
+		err := f.Save("ignores:alice", []string{"bob"})
+		f.Close()
+		reopened, openErr := Open(path)
+		var v []string
+		ok, loadErr := reopened.Load("ignores:alice", &v)
+
+		if err != nil || openErr != nil || loadErr != nil || !ok || len(v) != 1 || v[0] != "bob" {
+			t.Fatalf("Load() = (%v, %v, %v), want (%v, %v, %v)", v, ok, loadErr, []string{"bob"}, true, nil)
+		}
+	})
+
+	t.Run("should remove key when saving nil", func(t *testing.T) {
+		f, _ := Open(filepath.Join(t.TempDir(), "waddle.json"))
+
+		f.Save("ignores:alice", []string{"bob"})
+		f.Save("ignores:alice", nil)
+		var v []string
+		ok, err := f.Load("ignores:alice", &v)
+
+		if ok || err != nil {
+			t.Fatalf("Load() = (%v, %v), want (%v, %v)", ok, err, false, nil)
+		}
+	})
+
+	t.Run("should load saved values before they are written", func(t *testing.T) {
+		f, _ := Open(filepath.Join(t.TempDir(), "waddle.json"))
+		defer f.Close()
+
+		f.Save("ignores:alice", []string{"bob"})
+		var v []string
+		ok, err := f.Load("ignores:alice", &v)
+
+		if !ok || err != nil || len(v) != 1 || v[0] != "bob" {
+			t.Fatalf("Load() = (%v, %v, %v), want (%v, %v, %v)", v, ok, err, []string{"bob"}, true, nil)
+		}
+	})
+}
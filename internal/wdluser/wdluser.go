@@ -3,6 +3,7 @@ package wdluser
 import (
 	"bytes"
 	"io"
+	"net"
 )
 
 type User struct {
@@ -11,6 +12,31 @@ type User struct {
 	LoggedIn bool
 	Writer   io.Writer
 	Rooms    []string
+	// Oper is whether the user has authenticated as a server operator.
+	Oper bool
+	// Closer closes the user's connection. May be nil.
+	Closer io.Closer
+	// Account is the registered account the user has authenticated as, or
+	// empty if none.
+	Account string
+	// Ignores holds who this user does not want messages from, as
+	// "account:<account>" or "host:<host>", each with the name it was ignored
+	// under.
+	Ignores map[string]string
+	// Service is whether the user lives inside the server rather than being
+	// connected to it.
+	Service bool
+	// Caps holds the protocol capabilities the user has enabled.
+	Caps map[string]bool
+}
+
+// Host returns the address the user connected from without its port.
+func (u *User) Host() string {
+	host, _, err := net.SplitHostPort(u.Id)
+	if err != nil {
+		return u.Id
+	}
+	return host
 }
 
 // Writes OK to user. Return writer error.
@@ -29,3 +55,15 @@ func (u *User) Error(s string) error {
 	_, err := u.Writer.Write(buf.Bytes())
 	return err
 }
+
+// Writes PING and token to user. Returns writer error.
+func (u *User) Ping(token string) error {
+	_, err := u.Writer.Write([]byte("PING " + token + "\r\n"))
+	return err
+}
+
+// Writes PONG and token to user. Returns writer error.
+func (u *User) Pong(token string) error {
+	_, err := u.Writer.Write([]byte("PONG " + token + "\r\n"))
+	return err
+}
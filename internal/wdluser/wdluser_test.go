@@ -33,3 +33,49 @@ func TestError(t *testing.T) {
 		t.Fatalf("Ok() = %#q, want %#q", m.Wrote, expect)
 	}
 }
+
+func TestPing(t *testing.T) {
+	m := mock.MockWriter{Wrote: make([]byte, 0)}
+	u := User{
+		Writer: &m,
+	}
+
+	err := u.Ping("abc123")
+
+	expect := "PING abc123\r\n"
+	if err != nil || string(m.Wrote) != expect {
+		t.Fatalf("Ping() = %#q, want %#q", m.Wrote, expect)
+	}
+}
+
+func TestPong(t *testing.T) {
+	m := mock.MockWriter{Wrote: make([]byte, 0)}
+	u := User{
+		Writer: &m,
+	}
+
+	err := u.Pong("abc123")
+
+	expect := "PONG abc123\r\n"
+	if err != nil || string(m.Wrote) != expect {
+		t.Fatalf("Pong() = %#q, want %#q", m.Wrote, expect)
+	}
+}
+
+func TestHost(t *testing.T) {
+	tests := []struct {
+		id     string
+		expect string
+	}{
+		{"192.0.2.1:6667", "192.0.2.1"},
+		{"[2001:db8::1]:6667", "2001:db8::1"},
+		{"/run/waddle.sock#1", "/run/waddle.sock#1"},
+	}
+
+	for _, test := range tests {
+		u := User{Id: test.id}
+		if actual := u.Host(); actual != test.expect {
+			t.Errorf("Host() = %q, want %q", actual, test.expect)
+		}
+	}
+}
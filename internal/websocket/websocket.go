@@ -0,0 +1,309 @@
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Listener accepts WebSocket connections on an HTTP path and hands them out as
+// net.Conns, so they can be served like any other connection. Each text or
+// binary frame sent by a client is read as a stream of bytes, and each Write
+// is sent as one text frame.
+type Listener struct {
+	ln    net.Listener
+	path  string
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+	err   error
+}
+
+// Listen serves WebSocket upgrades for the given path on the listener.
+func Listen(ln net.Listener, path string) *Listener {
+	l := &Listener{
+		ln:    ln,
+		path:  path,
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, l.upgrade)
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := srv.Serve(ln)
+		l.once.Do(func() {
+			l.err = err
+			close(l.done)
+		})
+	}()
+
+	return l
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, l.err
+	}
+}
+
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// guid is appended to the client's key to prove the server speaks WebSocket.
+const guid = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// Accept returns the Sec-WebSocket-Accept value for the given client key.
+func Accept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + guid))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (l *Listener) upgrade(w http.ResponseWriter, r *http.Request) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, errBadHandshake, http.StatusBadRequest)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, errBadHandshake, http.StatusInternalServerError)
+		return
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	conn.SetDeadline(time.Time{})
+
+	_, err = conn.Write([]byte("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + Accept(key) + "\r\n\r\n"))
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	c := &Conn{Conn: conn, r: rw.Reader}
+	select {
+	case l.conns <- c:
+	case <-l.done:
+		conn.Close()
+	}
+}
+
+// headerContains returns whether any comma separated value of the header
+// equals the given token, ignoring case.
+func headerContains(h http.Header, name string, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Frame opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// Conn is the server side of a WebSocket connection.
+type Conn struct {
+	net.Conn
+	r         *bufio.Reader
+	wmu       sync.Mutex
+	remaining uint64
+	mask      [4]byte
+	maskPos   int
+	closed    bool
+}
+
+// Read reads the payload of data frames, answering control frames as they
+// arrive. Returns io.EOF once the client closes the connection.
+func (c *Conn) Read(b []byte) (int, error) {
+	for c.remaining == 0 {
+		if err := c.nextFrame(); err != nil {
+			return 0, err
+		}
+	}
+
+	if uint64(len(b)) > c.remaining {
+		b = b[:c.remaining]
+	}
+
+	n, err := c.r.Read(b)
+	for i := 0; i < n; i++ {
+		b[i] ^= c.mask[c.maskPos%4]
+		c.maskPos++
+	}
+	c.remaining -= uint64(n)
+
+	return n, err
+}
+
+// nextFrame reads frame headers until a data frame is found.
+func (c *Conn) nextFrame() error {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return err
+	}
+
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0f
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7f)
+
+	if !masked {
+		c.writeClose(1002)
+		return errors.New(errUnmasked)
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if _, err := io.ReadFull(c.r, c.mask[:]); err != nil {
+		return err
+	}
+	c.maskPos = 0
+
+	switch opcode {
+	case opContinuation, opText, opBinary:
+		c.remaining = length
+		return nil
+	case opClose, opPing, opPong:
+		if !fin || length > 125 {
+			c.writeClose(1002)
+			return errors.New(errBadControlFrame)
+		}
+	default:
+		c.writeClose(1002)
+		return errors.New(errBadOpcode)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return err
+	}
+	for i := range payload {
+		payload[i] ^= c.mask[i%4]
+	}
+
+	switch opcode {
+	case opPing:
+		return c.writeFrame(opPong, payload)
+	case opClose:
+		c.writeClose(1000)
+		return io.EOF
+	}
+
+	return nil
+}
+
+// Write sends the bytes as a single text frame.
+func (c *Conn) Write(b []byte) (int, error) {
+	if err := c.writeFrame(opText, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close sends a close frame and closes the connection.
+func (c *Conn) Close() error {
+	c.writeClose(1000)
+	return c.Conn.Close()
+}
+
+func (c *Conn) writeClose(code uint16) {
+	var payload [2]byte
+	binary.BigEndian.PutUint16(payload[:], code)
+	c.writeFrame(opClose, payload[:])
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+	if opcode == opClose {
+		c.closed = true
+	}
+
+	frame := make([]byte, 0, len(payload)+10)
+	frame = append(frame, 0x80|opcode)
+
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, byte(n>>8), byte(n))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, 127)
+		frame = append(frame, ext[:]...)
+	}
+	frame = append(frame, payload...)
+
+	_, err := c.Conn.Write(frame)
+	return err
+}
+
+const (
+	errBadControlFrame = "invalid control frame"
+	errBadHandshake    = "bad websocket handshake"
+	errBadOpcode       = "unknown frame opcode"
+	errUnmasked        = "client frames must be masked"
+)
@@ -0,0 +1,132 @@
+package websocket
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+// dial performs a client handshake against the listener and returns the
+// client's connection along with the server's side of it.
+func dial(t *testing.T, l *Listener) (net.Conn, *bufio.Reader, net.Conn) {
+	t.Helper()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.Write([]byte("GET /ws HTTP/1.1\r\n" +
+		"Host: localhost\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: keep-alive, Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+
+	r := bufio.NewReader(client)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != expect {
+		t.Fatalf("handshake = (%v, %q), want (%v, %q)",
+			resp.StatusCode, resp.Header.Get("Sec-WebSocket-Accept"), http.StatusSwitchingProtocols, expect)
+	}
+
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return client, r, server
+}
+
+func listen(t *testing.T) *Listener {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := Listen(ln, "/ws")
+	t.Cleanup(func() { l.Close() })
+
+	return l
+}
+
+// maskedFrame builds a client frame with the given opcode and payload.
+func maskedFrame(opcode byte, payload string) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i := range payload {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	return frame
+}
+
+func TestConn(t *testing.T) {
+	t.Run("should read masked text frames", func(t *testing.T) {
+		l := listen(t)
+		client, _, server := dial(t, l)
+		defer client.Close()
+
+		client.Write(maskedFrame(opText, "LOGIN alice\r\n"))
+		b := make([]byte, 1024)
+		n, err := server.Read(b)
+
+		expect := "LOGIN alice\r\n"
+		if err != nil || string(b[:n]) != expect {
+			t.Fatalf("Read() = (%#q, %v), want (%#q, %v)", b[:n], err, expect, nil)
+		}
+	})
+
+	t.Run("should write text frames", func(t *testing.T) {
+		l := listen(t)
+		client, r, server := dial(t, l)
+		defer client.Close()
+
+		server.Write([]byte("OK\r\n"))
+		frame := make([]byte, 6)
+		_, err := io.ReadFull(r, frame)
+
+		expect := "\x81\x04OK\r\n"
+		if err != nil || string(frame) != expect {
+			t.Fatalf("Write() sent %#q, want %#q", frame, expect)
+		}
+	})
+
+	t.Run("should answer ping and return EOF on close", func(t *testing.T) {
+		l := listen(t)
+		client, r, server := dial(t, l)
+		defer client.Close()
+
+		client.Write(maskedFrame(opPing, "hi"))
+		client.Write(maskedFrame(opClose, ""))
+		_, err := server.Read(make([]byte, 1024))
+		pong := make([]byte, 4)
+		io.ReadFull(r, pong)
+
+		if err != io.EOF || string(pong) != "\x8a\x02hi" {
+			t.Fatalf("Read() = %v, want EOF; sent %#q, want %#q", err, pong, "\x8a\x02hi")
+		}
+	})
+
+	t.Run("should fail on unmasked frames", func(t *testing.T) {
+		l := listen(t)
+		client, _, server := dial(t, l)
+		defer client.Close()
+
+		client.Write([]byte{0x81, 0x02, 'h', 'i'})
+		_, err := server.Read(make([]byte, 1024))
+
+		if err == nil {
+			t.Fatalf("Read() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,303 @@
+// Package plugin lets Go packages add commands to the server and follow what
+// users do, without changing the server itself.
+//
+// A plugin package registers itself from init, and the server enables the
+// plugins named in its configuration:
+//
+//	func init() {
+//		plugin.Register(plugin.Plugin{
+//			Name: "roll",
+//			Commands: []plugin.Command{{
+//				Name: "ROLL",
+//				Args: []plugin.Arg{{Name: "sides", Kind: plugin.Word}},
+//				Handler: func(s plugin.Server, c *plugin.Call) ([]string, error) {
+//					n, err := strconv.Atoi(c.Args[0])
+//					if err != nil || n < 1 {
+//						return nil, errors.New("invalid sides")
+//					}
+//					return []string{"ROLL " + strconv.Itoa(rand.Intn(n)+1)}, nil
+//				},
+//			}},
+//		})
+//	}
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/internal/parser"
+)
+
+// Plugin is a named set of commands and event handlers.
+type Plugin struct {
+	Name     string
+	Commands []Command
+
+	// OnEvent, when set, is called with every event. Events are delivered
+	// one at a time in the order they happened, after the server has
+	// finished acting on them.
+	OnEvent func(s Server, e Event)
+}
+
+// Command is a command clients may send. Name is the upper case keyword and
+// Args is its grammar. Lines the handler returns are sent to the caller before
+// OK, and an error is sent as ERROR.
+type Command struct {
+	Name    string
+	Args    []Arg
+	Handler func(s Server, c *Call) ([]string, error)
+
+	// Oper limits the command to server operators.
+	Oper bool
+}
+
+// Kind is the kind of value an argument takes. Plugin commands are parsed by
+// the same parser as the server's own, so their arguments follow the same
+// rules.
+type Kind = parser.Kind
+
+const (
+	// Word is a single word.
+	Word = parser.Word
+	// Room is a chatroom name, which must begin with '#'.
+	Room = parser.Room
+	// Text is the rest of the line, spaces included. It must be the last
+	// argument.
+	Text = parser.Text
+)
+
+// Arg is an argument of a command. Optional arguments may only be followed by
+// other optional arguments.
+type Arg = parser.Arg
+
+// Call is a command sent by a user. Args holds a value for each argument
+// given, so optional arguments left out are missing from the end.
+type Call struct {
+	User User
+	Args []string
+}
+
+// User is what the server knows about a logged in user.
+type User struct {
+	Name    string
+	Address string
+	Rooms   []string
+	Account string
+	Oper    bool
+	Service bool
+}
+
+// EventType is what happened in an Event.
+type EventType int
+
+const (
+	Login EventType = iota + 1
+	Join
+	Part
+	Message
+	Logout
+)
+
+// Event is something a user did at Time. Target is the chatroom for Join and
+// Part, and the chatroom or user a Message was sent to. Text is the message,
+// or the reason given for a Logout. Secret is whether Target is a secret (+s)
+// chatroom, whose members should only be revealed to each other.
+type Event struct {
+	Type   EventType
+	Time   time.Time
+	User   string
+	Target string
+	Text   string
+	Secret bool
+}
+
+// Server is what plugins may do to the server.
+type Server interface {
+	// SendUser sends a protocol line, without its CRLF, to the named user.
+	SendUser(name string, line string) error
+	// SendRoom sends a protocol line to every member of the chatroom.
+	SendRoom(room string, line string) error
+	// UserInfo returns the named user, or false if no such user is logged
+	// in.
+	UserInfo(name string) (User, bool)
+	// Members returns the names of the users in the chatroom.
+	Members(room string) []string
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = make(map[string]Plugin)
+)
+
+// Register makes a plugin available to be enabled by name. It panics if the
+// name is taken or a command's grammar is invalid, as both are programming
+// mistakes.
+func Register(p Plugin) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, ok := registry[p.Name]; ok {
+		panic("plugin: Register called twice for plugin " + p.Name)
+	}
+
+	for i := range p.Commands {
+		if err := p.Commands[i].validate(); err != nil {
+			panic("plugin: " + p.Name + ": " + err.Error())
+		}
+	}
+
+	registry[p.Name] = p
+}
+
+// Registered returns the names of the registered plugins, sorted.
+func Registered() []string {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (cmd *Command) validate() error {
+	if cmd.Name == "" || cmd.Name != strings.ToUpper(cmd.Name) || strings.ContainsAny(cmd.Name, " \r\n") {
+		return fmt.Errorf("command name %q must be one upper case word", cmd.Name)
+	}
+
+	if cmd.Handler == nil {
+		return fmt.Errorf("command %v has no handler", cmd.Name)
+	}
+
+	if err := parser.Validate(cmd.Args); err != nil {
+		return fmt.Errorf("command %v %v", cmd.Name, err.Error())
+	}
+
+	return nil
+}
+
+// Set is the plugins enabled on a server.
+type Set struct {
+	commands map[string]*Command
+	handlers []func(s Server, e Event)
+
+	mu     sync.Mutex
+	wake   *sync.Cond
+	queue  []pending
+	closed bool
+}
+
+// pending is an event waiting to be delivered.
+type pending struct {
+	s Server
+	e Event
+}
+
+// Load enables the named plugins. It fails if a plugin is not registered or
+// two plugins have a command of the same name.
+func Load(names []string) (*Set, error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	set := &Set{commands: make(map[string]*Command)}
+	set.wake = sync.NewCond(&set.mu)
+
+	for _, name := range names {
+		p, ok := registry[name]
+		if !ok {
+			return nil, errors.New(errUnknownPlugin + " " + name)
+		}
+
+		for i := range p.Commands {
+			cmd := p.Commands[i]
+			if _, ok := set.commands[cmd.Name]; ok {
+				return nil, errors.New(errDuplicateCommand + " " + cmd.Name)
+			}
+			set.commands[cmd.Name] = &cmd
+		}
+
+		if p.OnEvent != nil {
+			set.handlers = append(set.handlers, p.OnEvent)
+		}
+	}
+
+	go set.run()
+
+	return set, nil
+}
+
+// Command returns the enabled command with the given name.
+func (set *Set) Command(name string) (*Command, bool) {
+	cmd, ok := set.commands[name]
+	return cmd, ok
+}
+
+// Commands returns the names of the enabled commands, sorted.
+func (set *Set) Commands() []string {
+	names := make([]string, 0, len(set.commands))
+	for name := range set.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Emit queues the event for the plugins' event handlers. It never blocks, so
+// it may be called while the server holds its locks.
+func (set *Set) Emit(s Server, e Event) {
+	if len(set.handlers) == 0 {
+		return
+	}
+
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if !set.closed {
+		set.queue = append(set.queue, pending{s, e})
+		set.wake.Signal()
+	}
+}
+
+// Close stops delivering events once those already queued are delivered.
+func (set *Set) Close() {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	set.closed = true
+	set.wake.Signal()
+}
+
+// run delivers queued events until the set is closed.
+func (set *Set) run() {
+	for {
+		set.mu.Lock()
+		for len(set.queue) == 0 && !set.closed {
+			set.wake.Wait()
+		}
+		if len(set.queue) == 0 {
+			set.mu.Unlock()
+			return
+		}
+		p := set.queue[0]
+		set.queue = set.queue[1:]
+		set.mu.Unlock()
+
+		for _, handle := range set.handlers {
+			handle(p.s, p.e)
+		}
+	}
+}
+
+const (
+	errDuplicateCommand = "command registered by two plugins:"
+	errUnknownPlugin    = "unknown plugin"
+)
@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func noop(s Server, c *Call) ([]string, error) {
+	return nil, nil
+}
+
+// expectPanic fails the test unless f panics.
+func expectPanic(t *testing.T, f func()) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("did not panic")
+		}
+	}()
+	f()
+}
+
+func TestRegister(t *testing.T) {
+	t.Run("should panic on invalid grammar", func(t *testing.T) {
+		expectPanic(t, func() {
+			Register(Plugin{Name: "bad-text", Commands: []Command{{
+				Name:    "BAD",
+				Args:    []Arg{{Name: "text", Kind: Text}, {Name: "word", Kind: Word}},
+				Handler: noop,
+			}}})
+		})
+		expectPanic(t, func() {
+			Register(Plugin{Name: "bad-optional", Commands: []Command{{
+				Name:    "BAD",
+				Args:    []Arg{{Name: "a", Kind: Word, Optional: true}, {Name: "b", Kind: Word}},
+				Handler: noop,
+			}}})
+		})
+		expectPanic(t, func() {
+			Register(Plugin{Name: "bad-name", Commands: []Command{{Name: "bad", Handler: noop}}})
+		})
+	})
+
+	t.Run("should panic when registered twice", func(t *testing.T) {
+		Register(Plugin{Name: "twice"})
+
+		expectPanic(t, func() { Register(Plugin{Name: "twice"}) })
+	})
+}
+
+func TestLoad(t *testing.T) {
+	Register(Plugin{Name: "first", Commands: []Command{{Name: "SHARED", Handler: noop}, {Name: "FIRST", Handler: noop}}})
+	Register(Plugin{Name: "second", Commands: []Command{{Name: "SHARED", Handler: noop}}})
+
+	t.Run("should enable commands of named plugins", func(t *testing.T) {
+		set, err := Load([]string{"first"})
+		defer set.Close()
+
+		_, shared := set.Command("SHARED")
+		_, second := set.Command("SECOND")
+		if err != nil || !shared || second || strings.Join(set.Commands(), " ") != "FIRST SHARED" {
+			t.Fatalf("Load() = %v, commands %v, want %v and FIRST SHARED", err, set.Commands(), nil)
+		}
+	})
+
+	t.Run("should fail for unknown plugin", func(t *testing.T) {
+		_, err := Load([]string{"missing"})
+
+		if err == nil {
+			t.Fatalf("Load() = %v, want error", err)
+		}
+	})
+
+	t.Run("should fail when two plugins have the same command", func(t *testing.T) {
+		_, err := Load([]string{"first", "second"})
+
+		if err == nil {
+			t.Fatalf("Load() = %v, want error", err)
+		}
+	})
+}
+
+func TestEmit(t *testing.T) {
+	t.Run("should deliver events in order", func(t *testing.T) {
+		events := make(chan Event, 10)
+		Register(Plugin{Name: "events", OnEvent: func(s Server, e Event) { events <- e }})
+		set, _ := Load([]string{"events"})
+		defer set.Close()
+
+		set.Emit(nil, Event{Type: Login, User: "alice"})
+		set.Emit(nil, Event{Type: Join, User: "alice", Target: "#room"})
+
+		var got []Event
+		for len(got) < 2 {
+			select {
+			case e := <-events:
+				got = append(got, e)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("timed out waiting for events, got %+v", got)
+			}
+		}
+		if got[0].Type != Login || got[1].Type != Join || got[1].Target != "#room" {
+			t.Fatalf("events %+v, want Login then Join #room", got)
+		}
+	})
+}
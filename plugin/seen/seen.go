@@ -0,0 +1,115 @@
+// Package seen is a plugin adding a SEEN command, which tells when a user was
+// last active and what they were doing.
+//
+//	SEEN <username>
+//	SEEN <username> <time> <activity>
+//
+// Direct messages are recorded without who they were sent to, and secret
+// chatrooms are only named to their members.
+package seen
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ccassise/waddle/plugin"
+)
+
+func init() {
+	plugin.Register(newTracker().plugin())
+}
+
+// tracker remembers the last thing each user did.
+type tracker struct {
+	mu   sync.Mutex
+	last map[string]activity
+}
+
+// activity is what a user did, and in which chatroom if any. The chatroom of
+// a secret activity is only named to its members.
+type activity struct {
+	when   time.Time
+	what   string
+	room   string
+	secret bool
+}
+
+func newTracker() *tracker {
+	return &tracker{last: make(map[string]activity)}
+}
+
+func (t *tracker) plugin() plugin.Plugin {
+	return plugin.Plugin{
+		Name: "seen",
+		Commands: []plugin.Command{{
+			Name:    "SEEN",
+			Args:    []plugin.Arg{{Name: "username", Kind: plugin.Word}},
+			Handler: t.seen,
+		}},
+		OnEvent: t.record,
+	}
+}
+
+func (t *tracker) record(s plugin.Server, e plugin.Event) {
+	a := activity{when: e.Time, secret: e.Secret}
+	switch e.Type {
+	case plugin.Login:
+		a.what = "logging in"
+	case plugin.Join:
+		a.what, a.room = "joining", e.Target
+	case plugin.Part:
+		a.what, a.room = "leaving", e.Target
+	case plugin.Message:
+		a.what = "sending a direct message"
+		if strings.HasPrefix(e.Target, "#") {
+			a.what, a.room = "talking in", e.Target
+		}
+	case plugin.Logout:
+		a.what = "logging out"
+	default:
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.last[e.User] = a
+}
+
+func (t *tracker) seen(s plugin.Server, c *plugin.Call) ([]string, error) {
+	name := c.Args[0]
+
+	t.mu.Lock()
+	a, ok := t.last[name]
+	t.mu.Unlock()
+
+	if !ok {
+		return nil, errors.New(errNeverSeen)
+	}
+
+	what := a.what
+	if a.room != "" {
+		if a.secret && !member(c.User.Rooms, a.room) {
+			what += " a secret chatroom"
+		} else {
+			what += " " + a.room
+		}
+	}
+
+	return []string{"SEEN " + name + " " + a.when.UTC().Format(time.RFC3339) + " " + what}, nil
+}
+
+func member(rooms []string, room string) bool {
+	for _, r := range rooms {
+		if r == room {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	errNeverSeen = "never seen"
+)
@@ -0,0 +1,52 @@
+package seen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ccassise/waddle/plugin"
+)
+
+func TestSeen(t *testing.T) {
+	t.Run("should tell the last activity of a user", func(t *testing.T) {
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		tr := newTracker()
+
+		tr.record(nil, plugin.Event{Type: plugin.Login, Time: now.Add(-time.Hour), User: "alice"})
+		tr.record(nil, plugin.Event{Type: plugin.Message, Time: now, User: "alice", Target: "#room", Text: "hi"})
+		tr.record(nil, plugin.Event{Type: plugin.Message, Time: now, User: "bob", Target: "alice", Text: "psst"})
+		alice, aliceErr := tr.seen(nil, &plugin.Call{Args: []string{"alice"}})
+		bob, bobErr := tr.seen(nil, &plugin.Call{Args: []string{"bob"}})
+
+		expectAlice := "SEEN alice 2024-05-01T12:00:00Z talking in #room"
+		expectBob := "SEEN bob 2024-05-01T12:00:00Z sending a direct message"
+		if aliceErr != nil || bobErr != nil || alice[0] != expectAlice || bob[0] != expectBob {
+			t.Fatalf("seen() = (%q, %v) (%q, %v), want %q %q", alice, aliceErr, bob, bobErr, expectAlice, expectBob)
+		}
+	})
+
+	t.Run("should only name a secret chatroom to its members", func(t *testing.T) {
+		now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+		tr := newTracker()
+
+		tr.record(nil, plugin.Event{Type: plugin.Join, Time: now, User: "alice", Target: "#hideout", Secret: true})
+		outsider, _ := tr.seen(nil, &plugin.Call{User: plugin.User{Name: "carol"}, Args: []string{"alice"}})
+		member, _ := tr.seen(nil, &plugin.Call{User: plugin.User{Name: "bob", Rooms: []string{"#hideout"}}, Args: []string{"alice"}})
+
+		expectOutsider := "SEEN alice 2024-05-01T12:00:00Z joining a secret chatroom"
+		expectMember := "SEEN alice 2024-05-01T12:00:00Z joining #hideout"
+		if outsider[0] != expectOutsider || member[0] != expectMember {
+			t.Fatalf("seen() = %q %q, want %q %q", outsider, member, expectOutsider, expectMember)
+		}
+	})
+
+	t.Run("should fail for unknown user", func(t *testing.T) {
+		tr := newTracker()
+
+		_, err := tr.seen(nil, &plugin.Call{Args: []string{"carol"}})
+
+		if err == nil {
+			t.Fatalf("seen() = %v, want error", err)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/ccassise/waddle/internal/config"
+)
+
+// keepalive tracks the PING/PONG exchange of a single connection.
+type keepalive struct {
+	idle    time.Duration
+	timeout time.Duration
+	pending string
+	sent    time.Time
+}
+
+func newKeepalive(cfg config.Keepalive) keepalive {
+	return keepalive{
+		idle:    time.Duration(cfg.Idle),
+		timeout: time.Duration(cfg.Timeout),
+	}
+}
+
+// deadline returns when the next read should time out given the time of the
+// last activity. Returns the zero time when keepalives are disabled.
+func (k *keepalive) deadline(last time.Time) time.Time {
+	if k.idle <= 0 {
+		return time.Time{}
+	}
+
+	if k.pending != "" {
+		return k.sent.Add(k.timeout)
+	}
+
+	return last.Add(k.idle)
+}
+
+// expire is called when a read times out. Returns the token to send in a PING,
+// or an empty string when a PING is already unanswered and the client should
+// be dropped.
+func (k *keepalive) expire(now time.Time) string {
+	if k.pending != "" {
+		return ""
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	k.pending = hex.EncodeToString(b)
+	k.sent = now
+
+	return k.pending
+}
+
+// pong handles a PONG from the client.
+func (k *keepalive) pong(token string) error {
+	if k.pending == "" || token != k.pending {
+		return errors.New(errPongToken)
+	}
+
+	k.pending = ""
+	return nil
+}
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/ccassise/waddle/internal/config"
+	"github.com/ccassise/waddle/internal/connlimit"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/websocket"
+)
+
+// listener is a configured address along with the settings for connections
+// accepted on it.
+type listener struct {
+	net.Listener
+	cfg config.Listener
+
+	// tls is set when connections must complete a TLS handshake after any
+	// PROXY protocol header.
+	tls *tls.Config
+
+	// proxy is whether connections may begin with a PROXY protocol header.
+	proxy bool
+
+	seq uint64
+}
+
+func listen(cfg config.Listener) (*listener, error) {
+	l := &listener{cfg: cfg}
+
+	var err error
+	switch cfg.Network {
+	case "tcp":
+		l.proxy = true
+		l.Listener, err = net.Listen("tcp", cfg.Address)
+	case "tls":
+		l.proxy = true
+		l.tls, err = loadTLS(cfg)
+		if err != nil {
+			return nil, err
+		}
+		l.Listener, err = net.Listen("tcp", cfg.Address)
+	case "ws":
+		l.Listener, err = listenWebSocket(cfg)
+	case "unix":
+		l.Listener, err = listenUnix(cfg)
+	default:
+		return nil, fmt.Errorf("listener %q: unknown network %q", cfg.Address, cfg.Network)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func loadTLS(cfg config.Listener) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+func listenWebSocket(cfg config.Listener) (net.Listener, error) {
+	ln, err := net.Listen("tcp", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CertFile != "" {
+		tlsCfg, err := loadTLS(cfg)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+
+	path := cfg.Path
+	if path == "" {
+		path = "/"
+	}
+
+	return websocket.Listen(ln, path), nil
+}
+
+func listenUnix(cfg config.Listener) (net.Listener, error) {
+	mode := uint64(0660)
+	if cfg.Mode != "" {
+		var err error
+		mode, err = strconv.ParseUint(cfg.Mode, 8, 32)
+		if err != nil {
+			return nil, fmt.Errorf("listener %q: invalid mode %q", cfg.Address, cfg.Mode)
+		}
+	}
+
+	// Remove a socket left behind by a server that did not shut down cleanly.
+	if fi, err := os.Lstat(cfg.Address); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(cfg.Address)
+	}
+
+	ln, err := net.Listen("unix", cfg.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.Chmod(cfg.Address, os.FileMode(mode)); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
+
+// connID returns a unique id for a connection accepted on this listener.
+// Connections without an IP, such as those on Unix domain sockets, do not have
+// a unique remote address so they are numbered instead.
+func (l *listener) connID(conn net.Conn) string {
+	if connlimit.AddrIP(conn.RemoteAddr()) != nil {
+		return conn.RemoteAddr().String()
+	}
+
+	return fmt.Sprintf("%v#%d", l.Addr(), atomic.AddUint64(&l.seq, 1))
+}
+
+// checkPassword checks the password given to LOGIN against the one this
+// listener requires, if any.
+func (l *listener) checkPassword(m *message.Message) error {
+	if l.cfg.Password == "" {
+		return nil
+	}
+
+	if len(m.Args) == 0 || subtle.ConstantTimeCompare([]byte(m.Args[0]), []byte(l.cfg.Password)) != 1 {
+		return errors.New(errBadPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,492 @@
+// Package server runs a waddle chat server. The waddle command is only a call
+// to Main, so a server with plugins of its own is built by a main package that
+// imports them and calls Main:
+//
+//	package main
+//
+//	import (
+//		"github.com/ccassise/waddle/server"
+//
+//		_ "example.com/roll"
+//	)
+//
+//	func main() {
+//		server.Main()
+//	}
+package server
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ccassise/waddle/internal/audit"
+	"github.com/ccassise/waddle/internal/config"
+	"github.com/ccassise/waddle/internal/connlimit"
+	"github.com/ccassise/waddle/internal/context"
+	"github.com/ccassise/waddle/internal/filter"
+	"github.com/ccassise/waddle/internal/linereader"
+	"github.com/ccassise/waddle/internal/message"
+	"github.com/ccassise/waddle/internal/parser"
+	"github.com/ccassise/waddle/internal/proxyproto"
+	"github.com/ccassise/waddle/internal/ratelimit"
+	"github.com/ccassise/waddle/internal/sanitize"
+	"github.com/ccassise/waddle/internal/spam"
+	"github.com/ccassise/waddle/internal/store"
+	"github.com/ccassise/waddle/internal/wdluser"
+	"github.com/ccassise/waddle/plugin"
+)
+
+// server is the state shared by all connections.
+type server struct {
+	cfg     config.Config
+	ctx     *context.Context
+	limiter *ratelimit.Limiter
+	conns   *connlimit.Limiter
+	proxies []*net.IPNet
+	clean   *sanitize.Sanitizer
+}
+
+// Main reads the configuration named by the command line, serves it until the
+// process is sent SIGINT or SIGTERM, and exits on any error. The plugins it may
+// enable are those registered by the packages the program imports.
+func Main() {
+	configPath := flag.String("config", "", "path to JSON configuration file")
+	flag.Parse()
+
+	cfg := config.Default()
+	if *configPath != "" {
+		var err error
+		cfg, err = config.Load(*configPath)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+
+	if flag.NArg() > 0 {
+		cfg.Listeners = append(cfg.Listeners, config.Listener{
+			Network: "tcp",
+			Address: ":" + flag.Arg(0),
+		})
+	}
+
+	if len(cfg.Listeners) == 0 {
+		log.Fatalln("usage: [-config file] [port]")
+	}
+
+	conns, err := connlimit.New(cfg.Limits)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	proxies, err := connlimit.ParseCIDRs(cfg.Proxy.Trusted)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	filters, err := filter.New(cfg.Filters)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	detector, err := spam.New(cfg.Spam)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	clean, err := sanitize.New(cfg.Sanitize)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	auditLog, err := audit.Open(cfg.Audit)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+
+	plugins, err := plugin.Load(cfg.Plugins)
+	if err != nil {
+		log.Fatalln(err.Error())
+	}
+	for _, name := range plugins.Commands() {
+		cmd, _ := plugins.Command(name)
+		if err := parser.Register(name, cmd.Args); err != nil {
+			log.Fatalln("plugin " + err.Error())
+		}
+	}
+
+	opts := context.Options{
+		MaxUsers:         cfg.Limits.MaxUsers,
+		Opers:            cfg.Opers,
+		RejectIgnoredDMs: cfg.RejectIgnoredDMs,
+		Filter:           filters,
+		Spam:             detector,
+		Audit:            auditLog,
+		Plugins:          plugins,
+	}
+	var data *store.File
+	if cfg.DataFile != "" {
+		data, err = store.Open(cfg.DataFile)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		opts.Store = data
+	}
+
+	ctx := context.NewWithOptions(opts)
+	if cfg.Services.Echo != "" {
+		_, err := ctx.AddService(cfg.Services.Echo, func(svc *context.Service, m context.ServiceMessage) {
+			if m.Room == "" {
+				svc.Send(m.From, m.Text)
+			}
+		})
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+	}
+
+	s := server{
+		cfg:     cfg,
+		ctx:     &ctx,
+		clean:   clean,
+		limiter: ratelimit.New(cfg.RateLimit),
+		conns:   conns,
+		proxies: proxies,
+	}
+
+	var listeners []*listener
+	for _, lc := range cfg.Listeners {
+		l, err := listen(lc)
+		if err != nil {
+			log.Fatalln(err.Error())
+		}
+		listeners = append(listeners, l)
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range listeners {
+		wg.Add(1)
+		go func(l *listener) {
+			defer wg.Done()
+			s.serve(l)
+		}(l)
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		log.Println("Shutting down:", <-stop)
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	wg.Wait()
+
+	// Whatever the audit log and store have yet to write would be lost if
+	// the process just exited.
+	if err := auditLog.Close(); err != nil {
+		log.Println(err.Error())
+	}
+	if data != nil {
+		if err := data.Close(); err != nil {
+			log.Println(err.Error())
+		}
+	}
+	plugins.Close()
+}
+
+// serve accepts connections on the listener until it is closed.
+func (s *server) serve(l *listener) {
+	log.Println("Listening on", l.cfg.Network, l.Addr())
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		} else if err != nil {
+			log.Println(err.Error())
+			continue
+		}
+
+		log.Printf("%v connect", conn.RemoteAddr())
+		go s.handleConnection(conn, l)
+	}
+}
+
+func (s *server) handleConnection(conn net.Conn, l *listener) {
+	defer conn.Close()
+
+	if ip := connlimit.AddrIP(conn.RemoteAddr()); l.proxy && ip != nil && connlimit.Contains(s.proxies, ip) {
+		conn.SetReadDeadline(time.Now().Add(time.Duration(s.cfg.Proxy.Timeout)))
+		pc, err := proxyproto.ReadHeader(conn)
+		if err != nil {
+			log.Printf("%v proxy: %v\n", conn.RemoteAddr(), err.Error())
+			return
+		}
+		log.Printf("%v proxied %v\n", conn.RemoteAddr(), pc.RemoteAddr())
+		conn = pc
+	}
+
+	release, err := s.conns.Acquire(conn.RemoteAddr())
+	if err != nil {
+		log.Printf("%v rejected: %v\n", conn.RemoteAddr(), err.Error())
+		conn.SetWriteDeadline(time.Now().Add(rejectTimeout))
+		conn.Write([]byte("ERROR " + err.Error() + "\r\n"))
+		return
+	}
+	defer release()
+
+	if l.tls != nil {
+		tc := tls.Server(conn, l.tls)
+		tc.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tc.Handshake(); err != nil {
+			log.Printf("%v tls: %v\n", conn.RemoteAddr(), err.Error())
+			return
+		}
+		tc.SetDeadline(time.Time{})
+		conn = tc
+	}
+
+	user := wdluser.User{
+		Id:     l.connID(conn),
+		Writer: conn,
+		Closer: conn,
+	}
+	reason := quitClosed
+	defer func() { s.ctx.Quit(&user, reason) }()
+
+	limits := s.limiter.NewSession()
+	ka := newKeepalive(s.cfg.Keepalive)
+
+	conn.Write([]byte("HELLO\r\n"))
+
+	lines := linereader.New(conn, s.cfg.Protocol.MaxLineLength, s.cfg.Protocol.Strict)
+	last := time.Now()
+	for {
+		conn.SetReadDeadline(ka.deadline(last))
+
+		line, err := lines.ReadLine()
+		if lineErr, ok := err.(linereader.Error); ok {
+			log.Printf("%v[%q] ERROR %q\n", user.Id, user.Name, lineErr.Error())
+			user.Error(lineErr.Error())
+			last = time.Now()
+			continue
+		} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			token := ka.expire(time.Now())
+			if token == "" {
+				log.Printf("%v[%q] ping timeout\n", user.Id, user.Name)
+				reason = quitPingTimeout
+				return
+			}
+
+			user.Ping(token)
+			continue
+		} else if err != nil {
+			log.Printf("%v[%q] disconnect\n", user.Id, user.Name)
+			return
+		}
+		last = time.Now()
+
+		msg, err := parser.Parse(line)
+		if err == nil {
+			err = s.sanitize(&msg)
+		}
+		if err != nil {
+			log.Printf("%v[%q] ERROR %q\n", user.Id, user.Name, err.Error())
+			user.Error(err.Error())
+			continue
+		}
+
+		if msg.Command != message.Logout && msg.Command != message.Pong {
+			switch limits.Allow(commandClass(msg.Command), accountName(&user)) {
+			case ratelimit.Deny:
+				log.Printf("%v[%q] ERROR %q\n", user.Id, user.Name, errRateLimited)
+				user.Error(errRateLimited)
+				continue
+			case ratelimit.Disconnect:
+				log.Printf("%v[%q] flooding, disconnecting\n", user.Id, user.Name)
+				user.Error(errFlooding)
+				reason = quitFlooding
+				return
+			}
+		}
+
+		log.Printf("%v[%q] %v %q %q\n", user.Id, user.Name, message.StringifyCommand(msg.Command), msg.Receiver, msg.Data)
+		switch msg.Command {
+		case message.Ping:
+			err = user.Pong(msg.Data)
+		case message.Pong:
+			// PONG answers the server, so it gets no reply of its own that
+			// the client could mistake for the answer to a command.
+			if err := ka.pong(msg.Data); err != nil {
+				log.Printf("%v[%q] %v\n", user.Id, user.Name, err.Error())
+			}
+			continue
+		case message.Login:
+			if err = l.checkPassword(&msg); err == nil {
+				err = execute(s.ctx, &user, &msg)
+			}
+		default:
+			err = execute(s.ctx, &user, &msg)
+		}
+		if err != nil {
+			log.Printf("%v[%q] ERROR %q\n", user.Id, user.Name, err.Error())
+			user.Error(err.Error())
+			continue
+		}
+
+		user.Ok()
+
+		if msg.Command == message.Logout {
+			break
+		}
+	}
+}
+
+// sanitize cleans the text of a request. Passwords are left as they are, since
+// they are never sent to anyone and cleaning could stop them matching.
+func (s *server) sanitize(m *message.Message) error {
+	var err error
+	if m.Receiver, err = s.clean.String(m.Receiver); err != nil {
+		return err
+	}
+	if m.Data, err = s.clean.String(m.Data); err != nil {
+		return err
+	}
+
+	switch m.Command {
+	case message.Login, message.Oper, message.Register, message.Identify:
+		return nil
+	}
+
+	for i := range m.Args {
+		if m.Args[i], err = s.clean.String(m.Args[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func execute(ctx *context.Context, u *wdluser.User, m *message.Message) error {
+	switch m.Command {
+	case message.Login:
+		return ctx.Login(u, m)
+	case message.Logout:
+		return ctx.Quit(u, quitLogout)
+	case message.Join:
+		return ctx.Join(u, m)
+	case message.Part:
+		return ctx.Part(u, m)
+	case message.Msg:
+		return ctx.Broadcast(u, m)
+	case message.Op:
+		return ctx.Op(u, m)
+	case message.Deop:
+		return ctx.Deop(u, m)
+	case message.Kick:
+		return ctx.Kick(u, m)
+	case message.Ban:
+		return ctx.Ban(u, m)
+	case message.Unban:
+		return ctx.Unban(u, m)
+	case message.Banlist:
+		return ctx.Banlist(u, m)
+	case message.Mode:
+		return ctx.Mode(u, m)
+	case message.Invite:
+		return ctx.Invite(u, m)
+	case message.List:
+		return ctx.List(u, m)
+	case message.Names:
+		return ctx.Names(u, m)
+	case message.Cap:
+		return ctx.Cap(u, m)
+	case message.Plugin:
+		return ctx.Plugin(u, m)
+	case message.Oper:
+		return ctx.Oper(u, m)
+	case message.Kill:
+		return ctx.Kill(u, m)
+	case message.Gline:
+		return ctx.Gline(u, m)
+	case message.Ungline:
+		return ctx.Ungline(u, m)
+	case message.Wall:
+		return ctx.Wall(u, m)
+	case message.Close:
+		return ctx.Close(u, m)
+	case message.Rename:
+		return ctx.Rename(u, m)
+	case message.Mute:
+		return ctx.Mute(u, m)
+	case message.Unmute:
+		return ctx.Unmute(u, m)
+	case message.Shadowban:
+		return ctx.Shadowban(u, m)
+	case message.Ignore:
+		return ctx.Ignore(u, m)
+	case message.Unignore:
+		return ctx.Unignore(u, m)
+	case message.Ignorelist:
+		return ctx.Ignorelist(u, m)
+	case message.Register:
+		return ctx.Register(u, m)
+	case message.Identify:
+		return ctx.Identify(u, m)
+	case message.Audit:
+		return ctx.Audit(u, m)
+	}
+	return errors.New("internal error")
+}
+
+// commandClass returns the rate limiting class of the given command.
+func commandClass(command int) string {
+	switch command {
+	case message.Msg:
+		return ratelimit.ClassMsg
+	case message.Join, message.Part, message.Kick:
+		return ratelimit.ClassRoom
+	default:
+		return ratelimit.ClassDefault
+	}
+}
+
+// accountName returns the name that account rate limits are tracked under, or
+// an empty string when the user is not logged in.
+func accountName(u *wdluser.User) string {
+	if !u.LoggedIn {
+		return ""
+	}
+	return u.Name
+}
+
+const (
+	errBadPassword = "invalid password"
+	errFlooding    = "flooding, disconnecting"
+	errPongToken   = "unexpected pong token"
+	errRateLimited = "rate limited"
+)
+
+// rejectTimeout is how long a rejected connection is given to receive the
+// reason it was rejected.
+const rejectTimeout = 5 * time.Second
+
+// handshakeTimeout is how long a connection to a TLS listener is given to
+// finish its handshake, so that one which never does does not keep its place
+// under the connection limits.
+const handshakeTimeout = 10 * time.Second
+
+// Reasons given to other users when a user quits.
+const (
+	quitClosed      = "connection closed"
+	quitFlooding    = "flooding"
+	quitLogout      = "logout"
+	quitPingTimeout = "ping timeout"
+)